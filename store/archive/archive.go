@@ -0,0 +1,201 @@
+// Package archive keeps the tickets reaching a terminal status in a
+// database, so that they outlive their expiry from redis.
+package archive
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/emerishq/emeris-utils/database"
+	"github.com/emerishq/emeris-utils/store"
+)
+
+const defaultLimit = 100
+
+// Migrations create the table holding the archived tickets, run them with
+// database.RunMigrations.
+var Migrations = []string{
+	`CREATE DATABASE IF NOT EXISTS tickets`,
+	`CREATE TABLE IF NOT EXISTS tickets.archive (
+		ticket_key STRING PRIMARY KEY,
+		owner STRING NOT NULL,
+		chain STRING NOT NULL,
+		tx_hash STRING NOT NULL,
+		status STRING NOT NULL,
+		height INT8 NOT NULL DEFAULT 0,
+		ticket JSONB NOT NULL,
+		history JSONB NOT NULL,
+		archived_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		INDEX owner_idx (owner, archived_at DESC)
+	)`,
+}
+
+const (
+	upsertQuery = `UPSERT INTO tickets.archive
+		(ticket_key, owner, chain, tx_hash, status, height, ticket, history, archived_at)
+		VALUES (:ticket_key, :owner, :chain, :tx_hash, :status, :height, :ticket, :history, :archived_at)`
+
+	selectColumns = `SELECT ticket_key, chain, tx_hash, ticket, history, archived_at FROM tickets.archive`
+
+	getQuery = selectColumns + ` WHERE ticket_key = $1`
+
+	userTicketsQuery = selectColumns + ` WHERE owner IN ($1, $2) ORDER BY archived_at DESC LIMIT $3`
+)
+
+// ArchivedTicket is a ticket read from the archive, along with its history.
+type ArchivedTicket struct {
+	Key        string
+	Chain      string
+	TxHash     string
+	Ticket     store.Ticket
+	History    []store.TicketHistoryEntry
+	ArchivedAt time.Time
+}
+
+// row is a row of the archive table, tickets and histories are stored as
+// JSON.
+type row struct {
+	Key        string    `db:"ticket_key"`
+	Owner      string    `db:"owner"`
+	Chain      string    `db:"chain"`
+	TxHash     string    `db:"tx_hash"`
+	Status     string    `db:"status"`
+	Height     int64     `db:"height"`
+	Ticket     string    `db:"ticket"`
+	History    string    `db:"history"`
+	ArchivedAt time.Time `db:"archived_at"`
+}
+
+func (r row) archivedTicket() (ArchivedTicket, error) {
+	t := ArchivedTicket{
+		Key:        r.Key,
+		Chain:      r.Chain,
+		TxHash:     r.TxHash,
+		ArchivedAt: r.ArchivedAt,
+	}
+
+	if err := json.Unmarshal([]byte(r.Ticket), &t.Ticket); err != nil {
+		return ArchivedTicket{}, fmt.Errorf("malformed archived ticket %s, %w", r.Key, err)
+	}
+
+	if err := json.Unmarshal([]byte(r.History), &t.History); err != nil {
+		return ArchivedTicket{}, fmt.Errorf("malformed archived history %s, %w", r.Key, err)
+	}
+
+	return t, nil
+}
+
+// splitKey returns the chain and tx hash of a ticket key.
+func splitKey(key string) (string, string, error) {
+	s := strings.Split(key, "/")
+	if len(s) != 2 {
+		return "", "", fmt.Errorf("%w: %s", store.ErrMalformedTicketKey, key)
+	}
+
+	return s[0], s[1], nil
+}
+
+// Archive stores tickets in the table created by Migrations.
+type Archive struct {
+	db *database.Instance
+
+	// OwnerFallback must be the one of the archived store.
+	OwnerFallback store.OwnerFallback
+}
+
+// New returns an Archive storing tickets through db.
+func New(db *database.Instance) *Archive {
+	return &Archive{db: db}
+}
+
+// Put archives the ticket stored at key along with its history, replacing
+// the previous version, if any.
+func (a *Archive) Put(ctx context.Context, key string, ticket store.Ticket, history []store.TicketHistoryEntry) error {
+	chain, txHash, err := splitKey(key)
+	if err != nil {
+		return err
+	}
+
+	t, err := json.Marshal(ticket)
+	if err != nil {
+		return fmt.Errorf("cannot encode ticket %s, %w", key, err)
+	}
+
+	if history == nil {
+		history = []store.TicketHistoryEntry{}
+	}
+	h, err := json.Marshal(history)
+	if err != nil {
+		return fmt.Errorf("cannot encode history %s, %w", key, err)
+	}
+
+	_, err = a.db.DB.NamedExecContext(ctx, upsertQuery, row{
+		Key:        key,
+		Owner:      ticket.Owner,
+		Chain:      chain,
+		TxHash:     txHash,
+		Status:     string(ticket.Status),
+		Height:     ticket.Height,
+		Ticket:     string(t),
+		History:    string(h),
+		ArchivedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("cannot archive ticket %s, %w", key, err)
+	}
+
+	return nil
+}
+
+// Get returns the archived ticket stored at key, or store.ErrTicketNotFound.
+func (a *Archive) Get(ctx context.Context, key string) (ArchivedTicket, error) {
+	var r row
+	if err := a.db.DB.GetContext(ctx, &r, getQuery, key); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ArchivedTicket{}, store.ErrTicketNotFound
+		}
+
+		return ArchivedTicket{}, fmt.Errorf("cannot read archived ticket %s, %w", key, err)
+	}
+
+	return r.archivedTicket()
+}
+
+// UserTickets returns the archived tickets of user, as passed to
+// CreateTicket, most recently archived first. At most limit tickets are
+// returned, 100 if limit is not positive.
+// Tickets archived before owners were normalized are included.
+func (a *Archive) UserTickets(ctx context.Context, user string, limit int) ([]ArchivedTicket, error) {
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+
+	owner, err := store.OwnerKey(user, a.OwnerFallback)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []row
+	raw := hex.EncodeToString([]byte(user))
+	if err := a.db.DB.SelectContext(ctx, &rows, userTicketsQuery, owner, raw, limit); err != nil {
+		return nil, fmt.Errorf("cannot read archived tickets, %w", err)
+	}
+
+	tickets := make([]ArchivedTicket, 0, len(rows))
+	for _, r := range rows {
+		t, err := r.archivedTicket()
+		if err != nil {
+			return nil, err
+		}
+
+		tickets = append(tickets, t)
+	}
+
+	return tickets, nil
+}
@@ -0,0 +1,157 @@
+package archive_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/cockroach-go/v2/testserver"
+	"github.com/stretchr/testify/require"
+
+	"github.com/emerishq/emeris-utils/database"
+	"github.com/emerishq/emeris-utils/store"
+	"github.com/emerishq/emeris-utils/store/archive"
+)
+
+const (
+	testChain      = "cosmos-hub"
+	testTxHash     = "918DC23785CABA3EE4E4A59321E679F8B7A2E27C9DFB165B3B6D22EF23017264"
+	testOwner      = "cosmos1l2lepugxx5heetsl2cs74e2sy0uqxv390as7zw"
+	testDestChain  = "cosmos-hub-2"
+	testSrcChannel = "channel-1"
+	testPktSeq     = "1"
+)
+
+func setupArchive(t *testing.T) *archive.Archive {
+	t.Helper()
+
+	ts, err := testserver.NewTestServer()
+	require.NoError(t, err)
+	require.NoError(t, ts.WaitForInit())
+	t.Cleanup(ts.Stop)
+
+	require.NoError(t, database.RunMigrations(ts.PGURL().String(), archive.Migrations))
+
+	db, err := database.New(ts.PGURL().String())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	return archive.New(db)
+}
+
+func setupStore(t *testing.T) *store.Store {
+	t.Helper()
+
+	m, s := store.SetupTestStore()
+	t.Cleanup(m.Close)
+
+	return s
+}
+
+func TestArchivePutGet(t *testing.T) {
+	ctx := context.Background()
+	a := setupArchive(t)
+	key := store.GetKey(testChain, testTxHash)
+
+	_, err := a.Get(ctx, key)
+	require.ErrorIs(t, err, store.ErrTicketNotFound)
+
+	ticket := store.Ticket{Owner: "6f776e6572", Status: store.StatusComplete, Height: 12}
+	history := []store.TicketHistoryEntry{
+		{ID: "1-0", Status: store.StatusPending, TxHash: testTxHash, Timestamp: time.Now().UTC()},
+		{ID: "2-0", Status: store.StatusComplete, Height: 12, Timestamp: time.Now().UTC()},
+	}
+	require.NoError(t, a.Put(ctx, key, ticket, history))
+
+	archived, err := a.Get(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, testChain, archived.Chain)
+	require.Equal(t, testTxHash, archived.TxHash)
+	require.Equal(t, ticket, archived.Ticket)
+	require.Len(t, archived.History, 2)
+	require.Equal(t, store.StatusComplete, archived.History[1].Status)
+
+	// archiving again replaces the previous version
+	ticket.Status = store.StatusFailed
+	require.NoError(t, a.Put(ctx, key, ticket, nil))
+	archived, err = a.Get(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, store.StatusFailed, archived.Ticket.Status)
+	require.Empty(t, archived.History)
+
+	require.Error(t, a.Put(ctx, "malformed", ticket, nil))
+}
+
+func TestArchiver(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	a := setupArchive(t)
+	s := setupStore(t)
+
+	// tickets completed before the archiver starts are archived too
+	completeKey := store.GetKey(testChain, "COMPLETE")
+	require.NoError(t, s.CreateTicket(testChain, "COMPLETE", testOwner))
+	require.NoError(t, s.SetComplete(completeKey, 9))
+
+	archiver := archive.NewArchiver(s, a)
+	errs := make(chan error, 1)
+	archiver.OnError = func(_ string, err error) { errs <- err }
+	require.NoError(t, archiver.Start(ctx))
+
+	key := store.GetKey(testChain, testTxHash)
+	require.NoError(t, s.CreateTicket(testChain, testTxHash, testOwner))
+	require.NoError(t, s.SetInTransit(key, testDestChain, testSrcChannel, testPktSeq, testTxHash, testChain, 10))
+	require.NoError(t, s.SetIbcReceived(store.GetIBCKey(testDestChain, testSrcChannel, testPktSeq), "RECV", testDestChain, 11))
+
+	for _, k := range []string{completeKey, key} {
+		require.Eventually(t, func() bool {
+			_, err := a.Get(ctx, k)
+			return err == nil
+		}, 10*time.Second, 50*time.Millisecond)
+	}
+	require.Empty(t, errs)
+
+	archived, err := a.Get(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, store.StatusIBCReceiveSuccess, archived.Ticket.Status)
+	require.Len(t, archived.History, 3)
+}
+
+func TestReader(t *testing.T) {
+	ctx := context.Background()
+	a := setupArchive(t)
+	clock := store.NewManualClock(time.Now())
+	s := store.NewMemoryStore(clock)
+	r := archive.Reader{Store: s, Archive: a}
+
+	key := store.GetKey(testChain, testTxHash)
+	require.NoError(t, s.CreateTicketContext(ctx, testChain, testTxHash, testOwner))
+	require.NoError(t, s.SetCompleteContext(ctx, key, 12))
+	ticket, err := s.GetContext(ctx, key)
+	require.NoError(t, err)
+	history, err := s.TicketHistoryContext(ctx, key)
+	require.NoError(t, err)
+	require.NoError(t, a.Put(ctx, key, ticket, history))
+	require.NoError(t, s.CreateTicketContext(ctx, testChain, "PENDING", testOwner))
+
+	tickets, err := r.UserTickets(ctx, testOwner)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{testTxHash, "PENDING"}, tickets[testChain])
+
+	// the completed ticket expires from the store
+	clock.Advance(2*s.Config.ExpiryTime + time.Second)
+	ticket, err = r.Ticket(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, store.StatusComplete, ticket.Status)
+
+	history, err = r.TicketHistory(ctx, key)
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+
+	_, err = r.Ticket(ctx, store.GetKey(testChain, "UNKNOWN"))
+	require.ErrorIs(t, err, store.ErrTicketNotFound)
+	history, err = r.TicketHistory(ctx, store.GetKey(testChain, "UNKNOWN"))
+	require.NoError(t, err)
+	require.Empty(t, history)
+}
@@ -0,0 +1,133 @@
+package archive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/emerishq/emeris-utils/store"
+)
+
+const (
+	defaultArchiverGroup = "archiver"
+
+	archiveBatchSize  = 100
+	archiveBlock      = 5 * time.Second
+	archiveRetryDelay = 5 * time.Second
+)
+
+// Archiver archives the tickets of a Store as they reach a terminal status.
+// It reads the terminal ticket events as a consumer group of the store, so
+// tickets reaching a terminal status while no Archiver is running are
+// archived once one starts. An event is acknowledged once its ticket is
+// archived, or found expired.
+type Archiver struct {
+	store   *store.Store
+	archive *Archive
+
+	// Group is the consumer group reading the terminal ticket events,
+	// defaults to "archiver". Archivers sharing a Group split the events.
+	Group string
+
+	// Consumer identifies the Archiver within its Group, defaults to Group.
+	// An Archiver restarting with the same Consumer first archives the
+	// tickets it read but didn't archive before stopping.
+	Consumer string
+
+	// OnError, if not nil, is called with the errors met while archiving
+	// a ticket, key is empty when reading the events failed.
+	OnError func(key string, err error)
+}
+
+// NewArchiver returns an Archiver copying the tickets of s to a.
+func NewArchiver(s *store.Store, a *Archive) *Archiver {
+	return &Archiver{
+		store:   s,
+		archive: a,
+		Group:   defaultArchiverGroup,
+	}
+}
+
+// Start creates the consumer group of the Archiver if needed, then archives
+// the tickets moving to a terminal status in the background, until ctx is
+// done. Failed tickets are retried until they're archived.
+func (ar *Archiver) Start(ctx context.Context) error {
+	if ar.Group == "" {
+		ar.Group = defaultArchiverGroup
+	}
+
+	if ar.Consumer == "" {
+		ar.Consumer = ar.Group
+	}
+
+	if err := ar.store.CreateTerminalEventsGroup(ctx, ar.Group); err != nil {
+		return err
+	}
+
+	go func() {
+		for ctx.Err() == nil {
+			if err := ar.archiveEvents(ctx); err != nil {
+				select {
+				case <-ctx.Done():
+				case <-time.After(archiveRetryDelay):
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+// archiveEvents archives the tickets of a batch of terminal events, and
+// acknowledges them. Events are left pending from the first ticket that
+// can't be archived, which is then read again.
+func (ar *Archiver) archiveEvents(ctx context.Context) error {
+	events, err := ar.store.ReadTerminalEvents(ctx, ar.Group, ar.Consumer, archiveBatchSize, archiveBlock)
+	if err != nil {
+		if ctx.Err() == nil {
+			ar.onError("", err)
+		}
+		return err
+	}
+
+	for _, e := range events {
+		err := ar.ArchiveTicket(ctx, e.Key)
+		if err != nil {
+			ar.onError(e.Key, err)
+		}
+
+		// expired tickets can't be archived anymore
+		if err != nil && !errors.Is(err, store.ErrTicketNotFound) {
+			return err
+		}
+
+		if err := ar.store.AckTerminalEvents(ctx, ar.Group, e.ID); err != nil {
+			ar.onError(e.Key, err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (ar *Archiver) onError(key string, err error) {
+	if ar.OnError != nil {
+		ar.OnError(key, err)
+	}
+}
+
+// ArchiveTicket archives the ticket stored at key along with its history.
+func (ar *Archiver) ArchiveTicket(ctx context.Context, key string) error {
+	ticket, err := ar.store.GetContext(ctx, key)
+	if err != nil {
+		return fmt.Errorf("cannot read ticket %s, %w", key, err)
+	}
+
+	history, err := ar.store.TicketHistoryContext(ctx, key)
+	if err != nil {
+		return fmt.Errorf("cannot read history of ticket %s, %w", key, err)
+	}
+
+	return ar.archive.Put(ctx, key, ticket, history)
+}
@@ -0,0 +1,84 @@
+package archive
+
+import (
+	"context"
+	"errors"
+
+	"github.com/emerishq/emeris-utils/store"
+)
+
+// Reader reads tickets from a TicketStore, and from the archive once they
+// expired from it.
+type Reader struct {
+	Store   store.TicketStore
+	Archive *Archive
+
+	// Limit is the maximum number of archived tickets returned by
+	// UserTickets, defaults to 100.
+	Limit int
+}
+
+// Ticket returns the ticket stored at key.
+func (r Reader) Ticket(ctx context.Context, key string) (store.Ticket, error) {
+	ticket, err := r.Store.GetContext(ctx, key)
+	if !errors.Is(err, store.ErrTicketNotFound) {
+		return ticket, err
+	}
+
+	archived, err := r.Archive.Get(ctx, key)
+	if err != nil {
+		return store.Ticket{}, err
+	}
+
+	return archived.Ticket, nil
+}
+
+// TicketHistory returns the status changes of the ticket stored at key,
+// oldest first.
+func (r Reader) TicketHistory(ctx context.Context, key string) ([]store.TicketHistoryEntry, error) {
+	history, err := r.Store.TicketHistoryContext(ctx, key)
+	if err != nil || len(history) > 0 {
+		return history, err
+	}
+
+	archived, err := r.Archive.Get(ctx, key)
+	if errors.Is(err, store.ErrTicketNotFound) {
+		return []store.TicketHistoryEntry{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return archived.History, nil
+}
+
+// UserTickets returns the tx hashes of the tickets of user, grouped by chain,
+// like TicketStore.GetUserTicketsContext. Tickets that reached a terminal
+// status are read from the archive.
+func (r Reader) UserTickets(ctx context.Context, user string) (map[string][]string, error) {
+	tickets, err := r.Store.GetUserTicketsContext(ctx, user)
+	if err != nil {
+		return map[string][]string{}, err
+	}
+
+	archived, err := r.Archive.UserTickets(ctx, user, r.Limit)
+	if err != nil {
+		return map[string][]string{}, err
+	}
+
+	// a ticket created over an archived one is listed once
+	seen := make(map[string]bool)
+	for chain, txHashes := range tickets {
+		for _, txHash := range txHashes {
+			seen[store.GetKey(chain, txHash)] = true
+		}
+	}
+
+	for _, t := range archived {
+		if !seen[t.Key] {
+			tickets[t.Chain] = append(tickets[t.Chain], t.TxHash)
+		}
+	}
+
+	return tickets, nil
+}
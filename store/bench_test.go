@@ -0,0 +1,75 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+const benchKeys = 1000
+
+// setupBenchTickets creates benchKeys tickets owned by testOwner.
+func setupBenchTickets(b *testing.B) {
+	b.Helper()
+
+	for i := 0; i < benchKeys; i++ {
+		if err := store.CreateTicket(testChain, fmt.Sprintf("BENCH%d", i), testOwner); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkUserTicketsGet reads the tickets of an owner one GET at a time.
+func BenchmarkUserTicketsGet(b *testing.B) {
+	defer ResetTestStore(mr, store)
+	setupBenchTickets(b)
+	ctx := context.Background()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tickets, err := store.GetUserTickets(testOwner)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		for _, txHash := range tickets[testChain] {
+			if _, err := store.GetContext(ctx, GetKey(testChain, txHash)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// BenchmarkListUserTickets reads the tickets of an owner with GetMany.
+func BenchmarkListUserTickets(b *testing.B) {
+	defer ResetTestStore(mr, store)
+	setupBenchTickets(b)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		page, err := store.ListUserTickets(testOwner, ListFilter{Limit: benchKeys}, 0)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		if len(page.Tickets) < benchKeys {
+			b.Fatalf("got %d tickets", len(page.Tickets))
+		}
+	}
+}
+
+func BenchmarkGetSwapFees(b *testing.B) {
+	defer ResetTestStore(mr, store)
+	for i := 0; i < benchKeys; i++ {
+		if err := store.SetPoolSwapFees("bench", "1", "stake"); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := store.GetSwapFees("bench"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
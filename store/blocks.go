@@ -32,8 +32,8 @@ func blockTimeKey(height int64) string {
 	return fmt.Sprintf(blockTimeFmt, height)
 }
 
-func (b *Blocks) queryRedis(key string) ([]byte, error) {
-	res, err := b.storeInstance.Client.Get(context.Background(), key).Result()
+func (b *Blocks) queryRedis(ctx context.Context, key string) ([]byte, error) {
+	res, err := b.storeInstance.Client.Get(ctx, key).Result()
 	if err != nil {
 		if err == redis.Nil {
 			return nil, ErrBlockNotFound
@@ -46,20 +46,35 @@ func (b *Blocks) queryRedis(key string) ([]byte, error) {
 }
 
 func (b *Blocks) Block(height int64) ([]byte, error) {
-	return b.queryRedis(blockKey(height))
+	return b.BlockContext(context.Background(), height)
+}
+
+// BlockContext is like Block, with a context.
+func (b *Blocks) BlockContext(ctx context.Context, height int64) ([]byte, error) {
+	return b.queryRedis(ctx, blockKey(height))
 }
 
 func (b *Blocks) SetLastBlockTime(t time.Time, height int64) error {
+	return b.SetLastBlockTimeContext(context.Background(), t, height)
+}
+
+// SetLastBlockTimeContext is like SetLastBlockTime, with a context.
+func (b *Blocks) SetLastBlockTimeContext(ctx context.Context, t time.Time, height int64) error {
 	// TODO: figure out how to get block time from block
 	mt, err := t.MarshalText()
 	if err != nil {
 		return err
 	}
-	return b.storeInstance.Client.Set(context.Background(), blockTimeKey(height), mt, defaultTimeout).Err()
+	return b.storeInstance.Client.Set(ctx, blockTimeKey(height), mt, defaultTimeout).Err()
 }
 
 func (b *Blocks) LastBlockTime(height int64) (time.Time, error) {
-	res, err := b.queryRedis(blockTimeKey(height))
+	return b.LastBlockTimeContext(context.Background(), height)
+}
+
+// LastBlockTimeContext is like LastBlockTime, with a context.
+func (b *Blocks) LastBlockTimeContext(ctx context.Context, height int64) (time.Time, error) {
+	res, err := b.queryRedis(ctx, blockTimeKey(height))
 	if err != nil {
 		return time.Time{}, err
 	}
@@ -69,5 +84,10 @@ func (b *Blocks) LastBlockTime(height int64) (time.Time, error) {
 }
 
 func (b *Blocks) Add(data []byte, height int64) error {
-	return b.storeInstance.Client.Set(context.Background(), blockKey(height), string(data), defaultTimeout).Err()
+	return b.AddContext(context.Background(), data, height)
+}
+
+// AddContext is like Add, with a context.
+func (b *Blocks) AddContext(ctx context.Context, data []byte, height int64) error {
+	return b.storeInstance.Client.Set(ctx, blockKey(height), string(data), defaultTimeout).Err()
 }
@@ -0,0 +1,304 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/emerishq/emeris-utils/exported/sdktypes"
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	poolsKey    = "pools"
+	paramsKey   = "params"
+	supplyKey   = "supply"
+	nodeInfoKey = "node_info"
+
+	cacheMetaPrefix = "meta"
+)
+
+// Cached values are stored as JSON under their historical key, so that raw
+// readers such as GetPools keep working. The write metadata lives in a hash
+// at meta{<key>}, in the same cluster slot as the value.
+
+// CacheMeta describes when a cached value was written.
+// Values written before metadata was recorded have a zero CacheMeta.
+type CacheMeta struct {
+	UpdatedAt time.Time
+	// Height is the block height the value was read at.
+	Height int64
+}
+
+// Age returns the time elapsed since the value was written.
+func (m CacheMeta) Age() time.Duration {
+	return time.Since(m.UpdatedAt)
+}
+
+// IsStale returns true if the value was written more than maxAge ago, or if
+// its write time is unknown.
+func (m CacheMeta) IsStale(maxAge time.Duration) bool {
+	return m.UpdatedAt.IsZero() || m.Age() > maxAge
+}
+
+// LiquidityPool is a pool of the liquidity module.
+type LiquidityPool struct {
+	ID                    uint64   `json:"id,string"`
+	TypeID                uint32   `json:"type_id"`
+	ReserveCoinDenoms     []string `json:"reserve_coin_denoms"`
+	ReserveAccountAddress string   `json:"reserve_account_address"`
+	PoolCoinDenom         string   `json:"pool_coin_denom"`
+}
+
+// PoolType is a pool type of the liquidity module.
+type PoolType struct {
+	ID                uint32 `json:"id"`
+	Name              string `json:"name"`
+	MinReserveCoinNum uint32 `json:"min_reserve_coin_num"`
+	MaxReserveCoinNum uint32 `json:"max_reserve_coin_num"`
+	Description       string `json:"description"`
+}
+
+// LiquidityParams are the parameters of the liquidity module.
+type LiquidityParams struct {
+	PoolTypes              []PoolType     `json:"pool_types"`
+	MinInitDepositAmount   sdktypes.Int   `json:"min_init_deposit_amount"`
+	InitPoolCoinMintAmount sdktypes.Int   `json:"init_pool_coin_mint_amount"`
+	MaxReserveCoinAmount   sdktypes.Int   `json:"max_reserve_coin_amount"`
+	PoolCreationFee        sdktypes.Coins `json:"pool_creation_fee"`
+	SwapFeeRate            sdktypes.Dec   `json:"swap_fee_rate"`
+	WithdrawFeeRate        sdktypes.Dec   `json:"withdraw_fee_rate"`
+	MaxOrderAmountRatio    sdktypes.Dec   `json:"max_order_amount_ratio"`
+	UnitBatchHeight        uint32         `json:"unit_batch_height"`
+	CircuitBreakerEnabled  bool           `json:"circuit_breaker_enabled"`
+}
+
+// NodeInfo describes the node the cached values are read from.
+type NodeInfo struct {
+	DefaultNodeInfo    DefaultNodeInfo `json:"default_node_info"`
+	ApplicationVersion VersionInfo     `json:"application_version"`
+}
+
+// DefaultNodeInfo is the Tendermint information of a node.
+type DefaultNodeInfo struct {
+	DefaultNodeID string `json:"default_node_id"`
+	ListenAddr    string `json:"listen_addr"`
+	Network       string `json:"network"`
+	Version       string `json:"version"`
+	Moniker       string `json:"moniker"`
+}
+
+// VersionInfo is the application version of a node.
+type VersionInfo struct {
+	Name             string `json:"name"`
+	AppName          string `json:"app_name"`
+	Version          string `json:"version"`
+	GitCommit        string `json:"git_commit"`
+	GoVersion        string `json:"go_version"`
+	CosmosSDKVersion string `json:"cosmos_sdk_version"`
+}
+
+// CachedPools are the liquidity pools read from the cache.
+type CachedPools struct {
+	CacheMeta
+	Pools []LiquidityPool
+}
+
+// CachedParams are the liquidity parameters read from the cache.
+type CachedParams struct {
+	CacheMeta
+	Params LiquidityParams
+}
+
+// CachedSupply is the total supply read from the cache.
+type CachedSupply struct {
+	CacheMeta
+	Supply sdktypes.Coins
+}
+
+// CachedNodeInfo is the node information read from the cache.
+type CachedNodeInfo struct {
+	CacheMeta
+	NodeInfo NodeInfo
+}
+
+type poolsValue struct {
+	Pools []LiquidityPool `json:"pools"`
+}
+
+type paramsValue struct {
+	Params LiquidityParams `json:"params"`
+}
+
+type supplyValue struct {
+	Supply sdktypes.Coins `json:"supply"`
+}
+
+func cacheMetaKey(key string) string {
+	return hashTagged(cacheMetaPrefix, key)
+}
+
+// SetPools caches the liquidity pools read at height.
+func (s *Store) SetPools(pools []LiquidityPool, height int64) error {
+	return s.SetPoolsContext(context.Background(), pools, height)
+}
+
+// SetPoolsContext is like SetPools, with a context.
+func (s *Store) SetPoolsContext(ctx context.Context, pools []LiquidityPool, height int64) error {
+	return s.setCached(ctx, poolsKey, poolsValue{Pools: pools}, height)
+}
+
+// CachedPools returns the cached liquidity pools.
+func (s *Store) CachedPools() (CachedPools, error) {
+	return s.CachedPoolsContext(context.Background())
+}
+
+// CachedPoolsContext is like CachedPools, with a context.
+func (s *Store) CachedPoolsContext(ctx context.Context) (CachedPools, error) {
+	var v poolsValue
+	meta, err := s.getCached(ctx, poolsKey, &v)
+	if err != nil {
+		return CachedPools{}, fmt.Errorf("cannot fetch pools from cache, %w", err)
+	}
+
+	return CachedPools{CacheMeta: meta, Pools: v.Pools}, nil
+}
+
+// SetParams caches the liquidity parameters read at height.
+func (s *Store) SetParams(params LiquidityParams, height int64) error {
+	return s.SetParamsContext(context.Background(), params, height)
+}
+
+// SetParamsContext is like SetParams, with a context.
+func (s *Store) SetParamsContext(ctx context.Context, params LiquidityParams, height int64) error {
+	return s.setCached(ctx, paramsKey, paramsValue{Params: params}, height)
+}
+
+// CachedParams returns the cached liquidity parameters.
+func (s *Store) CachedParams() (CachedParams, error) {
+	return s.CachedParamsContext(context.Background())
+}
+
+// CachedParamsContext is like CachedParams, with a context.
+func (s *Store) CachedParamsContext(ctx context.Context) (CachedParams, error) {
+	var v paramsValue
+	meta, err := s.getCached(ctx, paramsKey, &v)
+	if err != nil {
+		return CachedParams{}, fmt.Errorf("cannot fetch params from cache, %w", err)
+	}
+
+	return CachedParams{CacheMeta: meta, Params: v.Params}, nil
+}
+
+// SetSupply caches the total supply read at height.
+func (s *Store) SetSupply(supply sdktypes.Coins, height int64) error {
+	return s.SetSupplyContext(context.Background(), supply, height)
+}
+
+// SetSupplyContext is like SetSupply, with a context.
+func (s *Store) SetSupplyContext(ctx context.Context, supply sdktypes.Coins, height int64) error {
+	return s.setCached(ctx, supplyKey, supplyValue{Supply: supply}, height)
+}
+
+// CachedSupply returns the cached total supply.
+func (s *Store) CachedSupply() (CachedSupply, error) {
+	return s.CachedSupplyContext(context.Background())
+}
+
+// CachedSupplyContext is like CachedSupply, with a context.
+func (s *Store) CachedSupplyContext(ctx context.Context) (CachedSupply, error) {
+	var v supplyValue
+	meta, err := s.getCached(ctx, supplyKey, &v)
+	if err != nil {
+		return CachedSupply{}, fmt.Errorf("cannot fetch total supply from cache, %w", err)
+	}
+
+	return CachedSupply{CacheMeta: meta, Supply: v.Supply}, nil
+}
+
+// SetNodeInfo caches the node information read at height.
+func (s *Store) SetNodeInfo(info NodeInfo, height int64) error {
+	return s.SetNodeInfoContext(context.Background(), info, height)
+}
+
+// SetNodeInfoContext is like SetNodeInfo, with a context.
+func (s *Store) SetNodeInfoContext(ctx context.Context, info NodeInfo, height int64) error {
+	return s.setCached(ctx, nodeInfoKey, info, height)
+}
+
+// CachedNodeInfo returns the cached node information.
+func (s *Store) CachedNodeInfo() (CachedNodeInfo, error) {
+	return s.CachedNodeInfoContext(context.Background())
+}
+
+// CachedNodeInfoContext is like CachedNodeInfo, with a context.
+func (s *Store) CachedNodeInfoContext(ctx context.Context) (CachedNodeInfo, error) {
+	var v NodeInfo
+	meta, err := s.getCached(ctx, nodeInfoKey, &v)
+	if err != nil {
+		return CachedNodeInfo{}, fmt.Errorf("cannot fetch node info from cache, %w", err)
+	}
+
+	return CachedNodeInfo{CacheMeta: meta, NodeInfo: v}, nil
+}
+
+// setCached stores v as JSON at key, along with its write metadata.
+func (s *Store) setCached(ctx context.Context, key string, v interface{}, height int64) error {
+	bz, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("cannot encode %s, %w", key, err)
+	}
+
+	_, err = s.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, key, bz, 0)
+		pipe.HSet(ctx, cacheMetaKey(key),
+			"updated_at", time.Now().UTC().Format(time.RFC3339Nano),
+			"height", height,
+		)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.invalidateCached(ctx, key)
+}
+
+// getCached decodes the JSON stored at key into v and returns its write
+// metadata.
+func (s *Store) getCached(ctx context.Context, key string, v interface{}) (CacheMeta, error) {
+	bz, meta, err := s.readCached(ctx, key)
+	if err != nil {
+		return CacheMeta{}, err
+	}
+
+	if err := json.Unmarshal(bz, v); err != nil {
+		return CacheMeta{}, err
+	}
+
+	return meta, nil
+}
+
+func parseCacheMeta(fields map[string]string) (CacheMeta, error) {
+	var m CacheMeta
+
+	if ts := fields["updated_at"]; ts != "" {
+		t, err := time.Parse(time.RFC3339Nano, ts)
+		if err != nil {
+			return CacheMeta{}, fmt.Errorf("malformed cache timestamp, %w", err)
+		}
+		m.UpdatedAt = t
+	}
+
+	if h := fields["height"]; h != "" {
+		height, err := strconv.ParseInt(h, 10, 64)
+		if err != nil {
+			return CacheMeta{}, fmt.Errorf("malformed cache height, %w", err)
+		}
+		m.Height = height
+	}
+
+	return m, nil
+}
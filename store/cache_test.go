@@ -0,0 +1,92 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/emerishq/emeris-utils/exported/sdktypes"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCachedPools(t *testing.T) {
+	defer ResetTestStore(mr, store)
+	_, err := store.CachedPools()
+	require.Error(t, err)
+
+	pools := []LiquidityPool{{
+		ID:                1,
+		TypeID:            1,
+		ReserveCoinDenoms: []string{"stake", "uatom"},
+		PoolCoinDenom:     "pool1",
+	}}
+	require.NoError(t, store.SetPools(pools, 42))
+
+	cached, err := store.CachedPools()
+	require.NoError(t, err)
+	require.Equal(t, pools, cached.Pools)
+	require.Equal(t, int64(42), cached.Height)
+	require.False(t, cached.IsStale(time.Minute))
+
+	// raw readers get the JSON
+	bz, err := store.GetPools()
+	require.NoError(t, err)
+	require.JSONEq(t, `{"pools":[{"id":"1","type_id":1,"reserve_coin_denoms":["stake","uatom"],"reserve_account_address":"","pool_coin_denom":"pool1"}]}`, string(bz))
+}
+
+func TestCachedParams(t *testing.T) {
+	defer ResetTestStore(mr, store)
+	params := LiquidityParams{
+		PoolTypes:            []PoolType{{ID: 1, Name: "StandardLiquidityPool"}},
+		MinInitDepositAmount: sdktypes.NewInt(1000000),
+		SwapFeeRate:          sdktypes.NewDecWithPrec(3, 3),
+		PoolCreationFee:      sdktypes.NewCoins(sdktypes.NewInt64Coin("stake", 40)),
+		UnitBatchHeight:      1,
+	}
+	require.NoError(t, store.SetParams(params, 7))
+
+	cached, err := store.CachedParams()
+	require.NoError(t, err)
+	require.Equal(t, int64(7), cached.Height)
+	require.Equal(t, params.PoolTypes, cached.Params.PoolTypes)
+	require.True(t, params.MinInitDepositAmount.Equal(cached.Params.MinInitDepositAmount))
+	require.True(t, params.SwapFeeRate.Equal(cached.Params.SwapFeeRate))
+	require.Equal(t, params.PoolCreationFee.String(), cached.Params.PoolCreationFee.String())
+}
+
+func TestCachedSupply(t *testing.T) {
+	defer ResetTestStore(mr, store)
+	supply := sdktypes.NewCoins(sdktypes.NewInt64Coin("stake", 100), sdktypes.NewInt64Coin("uatom", 5))
+	require.NoError(t, store.SetSupply(supply, 3))
+
+	cached, err := store.CachedSupply()
+	require.NoError(t, err)
+	require.Equal(t, supply.String(), cached.Supply.String())
+	require.Equal(t, int64(3), cached.Height)
+}
+
+func TestCachedNodeInfo(t *testing.T) {
+	defer ResetTestStore(mr, store)
+	info := NodeInfo{
+		DefaultNodeInfo:    DefaultNodeInfo{Network: "cosmoshub-4", Moniker: "emeris"},
+		ApplicationVersion: VersionInfo{AppName: "gaiad", Version: "v7.0.0"},
+	}
+	require.NoError(t, store.SetNodeInfo(info, 9))
+
+	cached, err := store.CachedNodeInfo()
+	require.NoError(t, err)
+	require.Equal(t, info, cached.NodeInfo)
+	require.Equal(t, int64(9), cached.Height)
+}
+
+func TestCachedWithoutMeta(t *testing.T) {
+	defer ResetTestStore(mr, store)
+	// values written before metadata was recorded
+	require.NoError(t, store.Client.Set(context.Background(), supplyKey, `{"supply":[{"denom":"stake","amount":"10"}]}`, 0).Err())
+
+	cached, err := store.CachedSupply()
+	require.NoError(t, err)
+	require.Equal(t, "10stake", cached.Supply.String())
+	require.True(t, cached.UpdatedAt.IsZero())
+	require.True(t, cached.IsStale(time.Hour))
+}
@@ -0,0 +1,39 @@
+package store
+
+import (
+	"context"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Keys that must be updated along with a ticket in a single transaction embed
+// the ticket key in a hash tag, e.g. shadow{chain/txhash}: since ticket keys
+// hold no braces, Redis Cluster maps them to the same slot as the ticket.
+// The owner sets, the IBC packet keys and the pub/sub channels can't be
+// co-located with a ticket, in cluster mode they are written right after the
+// transaction is committed.
+
+// hashTagged returns key prefixed by prefix, hash tagged so that it lives in
+// the same cluster slot as key.
+func hashTagged(prefix, key string) string {
+	return prefix + "{" + key + "}"
+}
+
+// isCluster returns true if s runs against a Redis Cluster.
+func (s *Store) isCluster() bool {
+	_, ok := s.Client.(*redis.ClusterClient)
+	return ok
+}
+
+// forEachNode calls fn for every server holding a part of the keyspace: each
+// master of a Redis Cluster, or the only server otherwise.
+// In cluster mode fn is called concurrently.
+func (s *Store) forEachNode(ctx context.Context, fn func(ctx context.Context, client redis.UniversalClient) error) error {
+	if c, ok := s.Client.(*redis.ClusterClient); ok {
+		return c.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
+			return fn(ctx, client)
+		})
+	}
+
+	return fn(ctx, s.Client)
+}
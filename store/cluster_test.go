@@ -0,0 +1,96 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashTagged(t *testing.T) {
+	key := GetKey(testChain, testTxHash)
+	require.Equal(t, "shadow{"+key+"}", shadowKey(key))
+	require.Equal(t, "history{"+key+"}", historyKey(key))
+
+	for _, k := range []string{shadowKey(key), shadow + key} {
+		got, ok := ticketKeyFromShadow(k)
+		require.True(t, ok)
+		require.Equal(t, key, got)
+	}
+	_, ok := ticketKeyFromShadow(key)
+	require.False(t, ok)
+}
+
+func TestClusterStore(t *testing.T) {
+	m, err := miniredis.Run()
+	require.NoError(t, err)
+	defer m.Close()
+
+	s, err := NewClientWithOptions(Options{Addrs: []string{m.Addr()}, Cluster: true})
+	require.NoError(t, err)
+	require.True(t, s.isCluster())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := s.SubscribeTickets(ctx, EventFilter{Owner: testOwner})
+	require.NoError(t, err)
+
+	key := GetKey(testChain, testTxHash)
+	require.NoError(t, s.CreateTicket(testChain, testTxHash, testOwner))
+	tickets, err := s.GetUserTickets(testOwner)
+	require.NoError(t, err)
+	require.Len(t, tickets[testChain], 1)
+
+	require.NoError(t, s.SetInTransit(key, testDestChain, testSrcChannel,
+		testPktSeq, testTxHash, testChain, 123))
+	newKey := GetIBCKey(testDestChain, testSrcChannel, testPktSeq)
+	require.True(t, s.Exists(newKey))
+	require.NoError(t, s.SetIbcReceived(newKey, testTxHash, testDestChain, 144))
+
+	ticket, err := s.Get(key)
+	require.NoError(t, err)
+	require.Equal(t, StatusIBCReceiveSuccess, ticket.Status)
+	require.False(t, s.Exists(shadowKey(key)))
+	tickets, err = s.GetUserTickets(testOwner)
+	require.NoError(t, err)
+	require.Len(t, tickets[testChain], 0)
+
+	history, err := s.TicketHistory(key)
+	require.NoError(t, err)
+	require.Len(t, history, 3)
+
+	for _, status := range []TicketStatus{StatusPending, StatusTransit, StatusIBCReceiveSuccess} {
+		select {
+		case e := <-events:
+			require.Equal(t, status, e.NewStatus)
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for %s event", status)
+		}
+	}
+
+	// the event streams are written after the transaction
+	userEvents, err := s.UserTicketEvents(testOwner, "", 10)
+	require.NoError(t, err)
+	require.Len(t, userEvents, 3)
+	require.NoError(t, s.CreateTerminalEventsGroup(ctx, "group"))
+	terminal, err := s.ReadTerminalEvents(ctx, "group", "consumer", 10, time.Millisecond)
+	require.NoError(t, err)
+	require.Len(t, terminal, 1)
+	require.Equal(t, key, terminal[0].Key)
+
+	// owner sets are scanned on every master
+	require.NoError(t, s.CreateTicket(testChain, "PENDING", testOwner))
+	require.NoError(t, s.RecountOpenTickets())
+	open, err := s.Client.HGetAll(ctx, openTicketsKey).Result()
+	require.NoError(t, err)
+	require.Equal(t, "1", open[string(StatusPending)])
+	require.Equal(t, "0", open[string(StatusTransit)])
+
+	// swap fees live in the sorted set of their pool
+	require.NoError(t, s.SetPoolSwapFees("1", "1000", "stake"))
+	fees, err := s.GetSwapFees("1")
+	require.NoError(t, err)
+	require.Equal(t, "1000stake", fees.String())
+}
@@ -0,0 +1,22 @@
+package store_test
+
+import (
+	"testing"
+
+	"github.com/emerishq/emeris-utils/store"
+	"github.com/emerishq/emeris-utils/store/storetest"
+)
+
+func TestStoreConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.TicketStore {
+		m, s := store.SetupTestStore()
+		t.Cleanup(m.Close)
+		return s
+	})
+}
+
+func TestMemoryStoreConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.TicketStore {
+		return store.NewMemoryStore(nil)
+	})
+}
@@ -0,0 +1,325 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	ticketsChannel     = "tickets"
+	ownerChannelFmt    = "tickets/%s"
+	eventChannelBuffer = 100
+
+	ownerEventsFmt = "events/%s"
+	// maxOwnerEvents is the approximate number of events kept per owner
+	maxOwnerEvents = 100
+
+	terminalEventsKey = "events/terminal"
+	// maxTerminalEvents is the approximate number of terminal events kept
+	maxTerminalEvents = 100000
+)
+
+// The events of the tickets of an owner are also appended to a stream, so
+// that subscribers can read the ones they missed with UserTicketEvents. The
+// stream is capped, and expires once no event was appended to it for a while.
+// The events moving a ticket to a terminal status are also appended to a
+// stream that doesn't expire, read by consumer groups with
+// ReadTerminalEvents, so that they are processed even when no consumer is
+// running at the time they are published.
+
+// TicketEvent describes a ticket status change.
+type TicketEvent struct {
+	// ID is the position of the event in the stream of its owner, it is only
+	// set on the events returned by UserTicketEvents.
+	ID        string        `json:"id,omitempty"`
+	Key       string        `json:"key"`
+	Owner     string        `json:"owner,omitempty"`
+	OldStatus TicketStatus  `json:"old_status,omitempty"`
+	NewStatus TicketStatus  `json:"new_status"`
+	Height    int64         `json:"height,omitempty"`
+	TxHashes  []TxHashEntry `json:"tx_hashes,omitempty"`
+}
+
+func (e *TicketEvent) UnmarshalBinary(data []byte) error {
+	return json.Unmarshal(data, e)
+}
+func (e TicketEvent) MarshalBinary() (data []byte, err error) {
+	return json.Marshal(e)
+}
+
+// EventFilter selects the events delivered by SubscribeTickets.
+type EventFilter struct {
+	// Owner restricts the events to the tickets of a single owner, as passed
+	// to CreateTicket. All tickets are considered when empty.
+	Owner string
+
+	// Statuses restricts the events to the ones moving a ticket to one of
+	// the given statuses. All statuses are considered when empty.
+	Statuses []TicketStatus
+}
+
+func (f EventFilter) match(e TicketEvent) bool {
+	if len(f.Statuses) == 0 {
+		return true
+	}
+
+	for _, s := range f.Statuses {
+		if s == e.NewStatus {
+			return true
+		}
+	}
+
+	return false
+}
+
+func ownerChannel(owner string) string {
+	return fmt.Sprintf(ownerChannelFmt, owner)
+}
+
+func ownerEventsKey(owner string) string {
+	return fmt.Sprintf(ownerEventsFmt, owner)
+}
+
+// publishEvent queues the publication of e on the global and owner channels,
+// and its append to the owner stream.
+func (s *Store) publishEvent(ctx context.Context, pipe redis.Pipeliner, e TicketEvent) {
+	pipe.Publish(ctx, ticketsChannel, e)
+	if e.NewStatus.IsTerminal() {
+		pipe.XAdd(ctx, &redis.XAddArgs{
+			Stream: terminalEventsKey,
+			MaxLen: maxTerminalEvents,
+			Approx: true,
+			Values: map[string]interface{}{"event": e},
+		})
+	}
+
+	if e.Owner == "" {
+		return
+	}
+
+	key := ownerEventsKey(e.Owner)
+	pipe.XAdd(ctx, &redis.XAddArgs{
+		Stream: key,
+		MaxLen: maxOwnerEvents,
+		Approx: true,
+		Values: map[string]interface{}{"event": e},
+	})
+	pipe.Expire(ctx, key, s.eventsExpiry())
+	pipe.Publish(ctx, ownerChannel(e.Owner), e)
+}
+
+// UserTicketEvents returns at most count events of the tickets of user, as
+// passed to CreateTicket, following the event with id afterID, oldest first.
+// The oldest events kept are returned when afterID is empty.
+func (s *Store) UserTicketEvents(user, afterID string, count int64) ([]TicketEvent, error) {
+	return s.UserTicketEventsContext(context.Background(), user, afterID, count)
+}
+
+// UserTicketEventsContext is like UserTicketEvents, with a context.
+func (s *Store) UserTicketEventsContext(ctx context.Context, user, afterID string, count int64) ([]TicketEvent, error) {
+	// exclusive ranges need redis 6.2, afterID is read and skipped instead
+	start, n := "-", count
+	if afterID != "" {
+		start, n = afterID, count+1
+	}
+
+	owner, err := s.ownerKey(user)
+	if err != nil {
+		return nil, err
+	}
+
+	msgs, err := s.Client.XRangeN(ctx, ownerEventsKey(owner), start, "+", n).Result()
+	if err != nil {
+		return nil, fmt.Errorf("cannot read ticket events, %w", err)
+	}
+
+	events := make([]TicketEvent, 0, len(msgs))
+	for _, msg := range msgs {
+		// afterID may no longer be in the stream
+		if msg.ID == afterID || int64(len(events)) == count {
+			continue
+		}
+
+		e, err := parseEvent(msg)
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, e)
+	}
+
+	return events, nil
+}
+
+func parseEvent(msg redis.XMessage) (TicketEvent, error) {
+	v, _ := msg.Values["event"].(string)
+
+	var e TicketEvent
+	if err := e.UnmarshalBinary([]byte(v)); err != nil {
+		return TicketEvent{}, fmt.Errorf("malformed ticket event %s, %w", msg.ID, err)
+	}
+	e.ID = msg.ID
+
+	return e, nil
+}
+
+// CreateTerminalEventsGroup creates the consumer group group reading the
+// events moving a ticket to a terminal status, starting with the oldest
+// event kept. Creating an existing group is a no-op.
+func (s *Store) CreateTerminalEventsGroup(ctx context.Context, group string) error {
+	err := s.Client.XGroupCreateMkStream(ctx, terminalEventsKey, group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("cannot create ticket events group %s, %w", group, err)
+	}
+
+	return nil
+}
+
+// ReadTerminalEvents returns at most count events moving a ticket to a
+// terminal status, read by consumer as part of group. The events consumer
+// read before but didn't acknowledge with AckTerminalEvents are returned
+// first, then the events not yet read by group, waiting for at most block
+// for new ones.
+// Events are kept until the stream reaches about 100000 events, whether they
+// were acknowledged or not.
+func (s *Store) ReadTerminalEvents(ctx context.Context, group, consumer string, count int64, block time.Duration) ([]TicketEvent, error) {
+	// a negative block doesn't block, pending events are returned right away
+	events, err := s.readTerminalEvents(ctx, group, consumer, "0", count, -1)
+	if err != nil || len(events) > 0 {
+		return events, err
+	}
+
+	return s.readTerminalEvents(ctx, group, consumer, ">", count, block)
+}
+
+func (s *Store) readTerminalEvents(ctx context.Context, group, consumer, id string, count int64, block time.Duration) ([]TicketEvent, error) {
+	streams, err := s.Client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    group,
+		Consumer: consumer,
+		Streams:  []string{terminalEventsKey, id},
+		Count:    count,
+		Block:    block,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read ticket events, %w", err)
+	}
+
+	var (
+		events  []TicketEvent
+		trimmed []string
+	)
+	for _, stream := range streams {
+		for _, msg := range stream.Messages {
+			// pending events trimmed from the stream have no values
+			if msg.Values == nil {
+				trimmed = append(trimmed, msg.ID)
+				continue
+			}
+
+			e, err := parseEvent(msg)
+			if err != nil {
+				return nil, err
+			}
+
+			events = append(events, e)
+		}
+	}
+
+	if len(trimmed) > 0 {
+		if err := s.AckTerminalEvents(ctx, group, trimmed...); err != nil {
+			return nil, err
+		}
+	}
+
+	return events, nil
+}
+
+// AckTerminalEvents acknowledges the events with ids read by group, which
+// are then no longer returned by ReadTerminalEvents.
+func (s *Store) AckTerminalEvents(ctx context.Context, group string, ids ...string) error {
+	if err := s.Client.XAck(ctx, terminalEventsKey, group, ids...).Err(); err != nil {
+		return fmt.Errorf("cannot acknowledge ticket events, %w", err)
+	}
+
+	return nil
+}
+
+// SubscribeTickets returns a channel on which the ticket events matching
+// filter are delivered.
+// The subscription is active when SubscribeTickets returns, and lasts until
+// ctx is done, at which point the channel is closed.
+// Events published while the subscriber is not keeping up are buffered by the
+// redis client, and dropped once its buffer is full.
+func (s *Store) SubscribeTickets(ctx context.Context, filter EventFilter) (<-chan TicketEvent, error) {
+	channel := ticketsChannel
+	if filter.Owner != "" {
+		owner, err := s.ownerKey(filter.Owner)
+		if err != nil {
+			return nil, err
+		}
+		channel = ownerChannel(owner)
+	}
+
+	return deliverEvents(ctx, s.Client.Subscribe(ctx, channel), filter)
+}
+
+// SubscribeOwnerTickets is like SubscribeTickets, for the events of the
+// tickets of every owner, delivered through a single pattern subscription to
+// the channels of all owners. It lets a process dispatch the events of many
+// owners, keyed by their Owner, while holding a single connection.
+func (s *Store) SubscribeOwnerTickets(ctx context.Context) (<-chan TicketEvent, error) {
+	return deliverEvents(ctx, s.Client.PSubscribe(ctx, ownerChannel("*")), EventFilter{})
+}
+
+// deliverEvents waits for pubsub to be subscribed, then delivers its events
+// matching filter until ctx is done.
+func deliverEvents(ctx context.Context, pubsub *redis.PubSub, filter EventFilter) (<-chan TicketEvent, error) {
+	if _, err := pubsub.Receive(ctx); err != nil {
+		_ = pubsub.Close()
+		return nil, fmt.Errorf("cannot subscribe to ticket events, %w", err)
+	}
+
+	events := make(chan TicketEvent, eventChannelBuffer)
+	go func() {
+		defer close(events)
+		defer pubsub.Close()
+
+		msgs := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-msgs:
+				if !ok {
+					return
+				}
+
+				var e TicketEvent
+				if err := e.UnmarshalBinary([]byte(msg.Payload)); err != nil {
+					continue
+				}
+
+				if !filter.match(e) {
+					continue
+				}
+
+				select {
+				case events <- e:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return events, nil
+}
@@ -0,0 +1,159 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func receiveEvent(t *testing.T, events <-chan TicketEvent) TicketEvent {
+	t.Helper()
+	select {
+	case e := <-events:
+		return e
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for ticket event")
+	}
+
+	return TicketEvent{}
+}
+
+func TestSubscribeTickets(t *testing.T) {
+	defer ResetTestStore(mr, store)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	all, err := store.SubscribeTickets(ctx, EventFilter{})
+	require.NoError(t, err)
+	owned, err := store.SubscribeTickets(ctx, EventFilter{Owner: testOwner})
+	require.NoError(t, err)
+	other, err := store.SubscribeTickets(ctx, EventFilter{Owner: "someone else"})
+	require.NoError(t, err)
+	terminal, err := store.SubscribeTickets(ctx, EventFilter{Statuses: []TicketStatus{StatusComplete}})
+	require.NoError(t, err)
+
+	key := GetKey(testChain, testTxHash)
+	require.NoError(t, store.CreateTicket(testChain, testTxHash, testOwner))
+	require.NoError(t, store.SetComplete(key, 123))
+
+	for _, events := range []<-chan TicketEvent{all, owned} {
+		e := receiveEvent(t, events)
+		require.Equal(t, key, e.Key)
+		require.Equal(t, TicketStatus(""), e.OldStatus)
+		require.Equal(t, StatusPending, e.NewStatus)
+
+		e = receiveEvent(t, events)
+		require.Equal(t, key, e.Key)
+		require.Equal(t, StatusPending, e.OldStatus)
+		require.Equal(t, StatusComplete, e.NewStatus)
+		require.EqualValues(t, 123, e.Height)
+	}
+
+	e := receiveEvent(t, terminal)
+	require.Equal(t, StatusComplete, e.NewStatus)
+
+	select {
+	case e := <-other:
+		t.Fatalf("unexpected event %v", e)
+	default:
+	}
+
+	// events channel is closed with the context
+	cancel()
+	require.Eventually(t, func() bool {
+		_, ok := <-all
+		return !ok
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestUserTicketEvents(t *testing.T) {
+	defer ResetTestStore(mr, store)
+
+	events, err := store.UserTicketEvents(testOwner, "", 10)
+	require.NoError(t, err)
+	require.Empty(t, events)
+
+	key := GetKey(testChain, testTxHash)
+	require.NoError(t, store.CreateTicket(testChain, testTxHash, testOwner))
+	require.NoError(t, store.SetInTransit(key, testDestChain, testSrcChannel, testPktSeq, testTxHash, testChain, 10))
+	require.NoError(t, store.SetIbcReceived(GetIBCKey(testDestChain, testSrcChannel, testPktSeq), testTxHash, testDestChain, 11))
+
+	events, err = store.UserTicketEvents(testOwner, "", 10)
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+	require.Equal(t, StatusPending, events[0].NewStatus)
+	require.Equal(t, StatusIBCReceiveSuccess, events[2].NewStatus)
+	require.Equal(t, key, events[2].Key)
+	require.Equal(t, 2*store.Config.ExpiryTime, mr.TTL(ownerEventsKey(events[0].Owner)))
+
+	// resuming after the first event
+	resumed, err := store.UserTicketEvents(testOwner, events[0].ID, 1)
+	require.NoError(t, err)
+	require.Len(t, resumed, 1)
+	require.Equal(t, events[1], resumed[0])
+
+	resumed, err = store.UserTicketEvents(testOwner, events[2].ID, 10)
+	require.NoError(t, err)
+	require.Empty(t, resumed)
+
+	events, err = store.UserTicketEvents("someone else", "", 10)
+	require.NoError(t, err)
+	require.Empty(t, events)
+}
+
+func TestReadTerminalEvents(t *testing.T) {
+	defer ResetTestStore(mr, store)
+	ctx := context.Background()
+
+	key := GetKey(testChain, testTxHash)
+	require.NoError(t, store.CreateTicket(testChain, testTxHash, testOwner))
+	require.NoError(t, store.SetComplete(key, 123))
+
+	// events published before the group is created are read
+	require.NoError(t, store.CreateTerminalEventsGroup(ctx, "group"))
+	require.NoError(t, store.CreateTerminalEventsGroup(ctx, "group"))
+
+	events, err := store.ReadTerminalEvents(ctx, "group", "consumer", 10, time.Millisecond)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, key, events[0].Key)
+	require.Equal(t, StatusComplete, events[0].NewStatus)
+
+	// events are read again until acknowledged
+	other, err := store.ReadTerminalEvents(ctx, "group", "consumer", 10, time.Millisecond)
+	require.NoError(t, err)
+	require.Equal(t, events, other)
+
+	require.NoError(t, store.AckTerminalEvents(ctx, "group", events[0].ID))
+	events, err = store.ReadTerminalEvents(ctx, "group", "consumer", 10, time.Millisecond)
+	require.NoError(t, err)
+	require.Empty(t, events)
+
+	// other groups read all events
+	require.NoError(t, store.CreateTerminalEventsGroup(ctx, "other"))
+	events, err = store.ReadTerminalEvents(ctx, "other", "consumer", 10, time.Millisecond)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+}
+
+func TestSubscribeOwnerTickets(t *testing.T) {
+	defer ResetTestStore(mr, store)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := store.SubscribeOwnerTickets(ctx)
+	require.NoError(t, err)
+
+	require.NoError(t, store.CreateTicket(testChain, testTxHash, testOwner))
+	require.NoError(t, store.CreateTicket(testChain, "OTHER", testOsmoOwner))
+
+	ownerKey, err := OwnerKey(testOwner, nil)
+	require.NoError(t, err)
+	for _, key := range []string{GetKey(testChain, testTxHash), GetKey(testChain, "OTHER")} {
+		e := receiveEvent(t, events)
+		require.Equal(t, key, e.Key)
+		require.Equal(t, ownerKey, e.Owner)
+	}
+}
@@ -0,0 +1,201 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	expiredEventsFmt  = "__keyevent@%d__:expired"
+	keyspaceEventsKey = "notify-keyspace-events"
+)
+
+// ExpiredHandler is called with the key of a ticket whose shadow key expired.
+type ExpiredHandler func(ctx context.Context, key string) error
+
+// ExpiryWatcher listens for expired shadow keys and calls a handler with the
+// key of the ticket they belong to.
+type ExpiryWatcher struct {
+	store   *Store
+	handler ExpiredHandler
+
+	// SkipConfig disables the CONFIG SET call that enables expired keyspace
+	// notifications on the server. Set it when notifications are configured
+	// out of band, or when CONFIG is not available (managed instances,
+	// miniredis).
+	SkipConfig bool
+
+	// OnError, if not nil, is called with the errors returned by the handler.
+	OnError func(key string, err error)
+}
+
+// NewExpiryWatcher returns an ExpiryWatcher calling handler for every expired
+// shadow key of s.
+func NewExpiryWatcher(s *Store, handler ExpiredHandler) *ExpiryWatcher {
+	return &ExpiryWatcher{
+		store:   s,
+		handler: handler,
+	}
+}
+
+// Start enables expired keyspace notifications and subscribes to them.
+// Once subscribed, notifications are handled in the background until ctx is
+// done.
+// Keyspace notifications are local to each server: in cluster mode, Start
+// subscribes to every master known when it's called, and handles no
+// notification unless every subscription succeeds.
+func (w *ExpiryWatcher) Start(ctx context.Context) error {
+	var (
+		mu   sync.Mutex
+		subs []*redis.PubSub
+	)
+	err := w.store.forEachNode(ctx, func(ctx context.Context, client redis.UniversalClient) error {
+		pubsub, err := w.subscribe(ctx, client)
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		subs = append(subs, pubsub)
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		for _, pubsub := range subs {
+			_ = pubsub.Close()
+		}
+		return err
+	}
+
+	for _, pubsub := range subs {
+		w.watch(ctx, pubsub)
+	}
+
+	return nil
+}
+
+// subscribe enables expired keyspace notifications on client and subscribes
+// to them.
+func (w *ExpiryWatcher) subscribe(ctx context.Context, client redis.UniversalClient) (*redis.PubSub, error) {
+	if !w.SkipConfig {
+		if err := enableNotifications(ctx, client); err != nil {
+			return nil, err
+		}
+	}
+
+	pubsub := client.Subscribe(ctx, fmt.Sprintf(expiredEventsFmt, w.store.db))
+	if _, err := pubsub.Receive(ctx); err != nil {
+		_ = pubsub.Close()
+		return nil, fmt.Errorf("cannot subscribe to expired keys, %w", err)
+	}
+
+	return pubsub, nil
+}
+
+// watch handles the notifications received by pubsub in the background,
+// until ctx is done.
+func (w *ExpiryWatcher) watch(ctx context.Context, pubsub *redis.PubSub) {
+	go func() {
+		defer pubsub.Close()
+
+		msgs := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-msgs:
+				if !ok {
+					return
+				}
+
+				w.handle(ctx, msg.Payload)
+			}
+		}
+	}()
+}
+
+func (w *ExpiryWatcher) handle(ctx context.Context, expiredKey string) {
+	key, ok := ticketKeyFromShadow(expiredKey)
+	if !ok {
+		return
+	}
+
+	if err := w.handler(ctx, key); err != nil && w.OnError != nil {
+		w.OnError(key, err)
+	}
+}
+
+// ticketKeyFromShadow returns the ticket key a shadow key belongs to.
+func ticketKeyFromShadow(shadowKey string) (string, bool) {
+	if !strings.HasPrefix(shadowKey, shadow) {
+		return "", false
+	}
+
+	key := strings.TrimPrefix(shadowKey, shadow)
+	// shadow keys written before cluster support are not hash tagged
+	if strings.HasPrefix(key, "{") && strings.HasSuffix(key, "}") {
+		key = key[1 : len(key)-1]
+	}
+
+	return key, true
+}
+
+// enableNotifications adds expired events to the server keyspace
+// notifications, keeping the classes that are already enabled.
+func enableNotifications(ctx context.Context, client redis.UniversalClient) error {
+	res, err := client.ConfigGet(ctx, keyspaceEventsKey).Result()
+	if err != nil {
+		return fmt.Errorf("cannot read keyspace notifications config, %w", err)
+	}
+
+	var flags string
+	if len(res) == 2 {
+		flags, _ = res[1].(string)
+	}
+
+	if !strings.Contains(flags, "E") {
+		flags += "E"
+	}
+	if !strings.Contains(flags, "x") && !strings.Contains(flags, "A") {
+		flags += "x"
+	}
+
+	if err := client.ConfigSet(ctx, keyspaceEventsKey, flags).Err(); err != nil {
+		return fmt.Errorf("cannot enable keyspace notifications, %w", err)
+	}
+
+	return nil
+}
+
+// TimeoutStuckTickets returns an ExpiredHandler that moves pending and in
+// transit tickets to StatusTimeout.
+// Tickets that already progressed to another status, or that no longer
+// exist, are left untouched.
+func TimeoutStuckTickets(s TicketStore) ExpiredHandler {
+	return func(ctx context.Context, key string) error {
+		ticket, err := s.GetContext(ctx, key)
+		if errors.Is(err, redis.Nil) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if ticket.Status != StatusPending && ticket.Status != StatusTransit {
+			return nil
+		}
+
+		err = s.SetTimeoutContext(ctx, key)
+		if errors.Is(err, ErrInvalidTransition) || errors.Is(err, ErrConflict) {
+			// the ticket moved on while we were looking at it
+			return nil
+		}
+
+		return err
+	}
+}
@@ -0,0 +1,123 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// expireShadowKey emulates the notification a redis server sends when the
+// shadow key of key expires, since miniredis doesn't implement keyspace
+// notifications.
+func expireShadowKey(key string) {
+	mr.Del(getShadowKey(key))
+	mr.Publish("__keyevent@0__:expired", getShadowKey(key))
+}
+
+func TestExpiryWatcher(t *testing.T) {
+	defer ResetTestStore(mr, store)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		mu      sync.Mutex
+		expired []string
+	)
+	w := NewExpiryWatcher(store, func(ctx context.Context, key string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		expired = append(expired, key)
+		return nil
+	})
+	w.SkipConfig = true
+	require.NoError(t, w.Start(ctx))
+
+	key := GetKey(testChain, testTxHash)
+	// keys other than shadow ones are ignored
+	mr.Publish("__keyevent@0__:expired", key)
+	expireShadowKey(key)
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(expired) == 1
+	}, time.Second, 10*time.Millisecond)
+	require.Equal(t, key, expired[0])
+}
+
+func TestExpiryWatcherConfig(t *testing.T) {
+	// miniredis doesn't support CONFIG, enabling notifications must fail
+	w := NewExpiryWatcher(store, TimeoutStuckTickets(store))
+	require.Error(t, w.Start(context.Background()))
+}
+
+func TestTimeoutStuckTickets(t *testing.T) {
+	defer ResetTestStore(mr, store)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	w := NewExpiryWatcher(store, TimeoutStuckTickets(store))
+	w.SkipConfig = true
+	w.OnError = func(key string, err error) {
+		t.Errorf("unexpected error for key %s: %s", key, err)
+	}
+	require.NoError(t, w.Start(ctx))
+
+	pendingKey := GetKey(testChain, "pending")
+	require.NoError(t, store.CreateTicket(testChain, "pending", testOwner))
+	transitKey := GetKey(testChain, "transit")
+	require.NoError(t, store.CreateTicket(testChain, "transit", testOwner))
+	require.NoError(t, store.SetInTransit(transitKey, testDestChain, testSrcChannel,
+		testPktSeq, testTxHash, testChain, 123))
+	completeKey := GetKey(testChain, "complete")
+	require.NoError(t, store.CreateTicket(testChain, "complete", testOwner))
+	require.NoError(t, store.SetComplete(completeKey, 123))
+
+	for _, key := range []string{pendingKey, transitKey, completeKey, GetKey(testChain, "missing")} {
+		expireShadowKey(key)
+	}
+
+	for _, key := range []string{pendingKey, transitKey} {
+		require.Eventually(t, func() bool {
+			ticket, err := store.Get(key)
+			return err == nil && ticket.Status == StatusTimeout
+		}, time.Second, 10*time.Millisecond)
+	}
+
+	ticket, err := store.Get(completeKey)
+	require.NoError(t, err)
+	require.Equal(t, StatusComplete, ticket.Status)
+
+	tickets, err := store.GetUserTickets(testOwner)
+	require.NoError(t, err)
+	require.Len(t, tickets[testChain], 0)
+}
+
+func TestLegacyShadowKey(t *testing.T) {
+	defer ResetTestStore(mr, store)
+
+	// shadow keys written before cluster support
+	key := GetKey(testChain, testTxHash)
+	legacy := "shadow" + key
+	require.NoError(t, mr.Set(legacy, ""))
+	mr.SetTTL(legacy, time.Minute)
+
+	require.NoError(t, store.CreateTicketWithOptions(testChain, testTxHash, testOwner, CreateOptions{Force: true}))
+	require.False(t, mr.Exists(legacy))
+
+	require.NoError(t, mr.Set(legacy, ""))
+	mr.SetTTL(legacy, time.Minute)
+	require.NoError(t, store.SetInTransit(key, testDestChain, testSrcChannel,
+		testPktSeq, testTxHash, testChain, 123))
+	require.False(t, mr.Exists(legacy))
+
+	// the legacy key can't expire anymore, the ticket is not timed out
+	mr.FastForward(2 * time.Minute)
+	ticket, err := store.Get(key)
+	require.NoError(t, err)
+	require.Equal(t, StatusTransit, ticket.Status)
+	require.True(t, mr.Exists(getShadowKey(key)))
+}
@@ -0,0 +1,122 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const historyPrefix = "history"
+
+// TicketHistoryEntry is a single status change of a ticket.
+type TicketHistoryEntry struct {
+	ID            string        `json:"id"`
+	Status        TicketStatus  `json:"status"`
+	Height        int64         `json:"height,omitempty"`
+	Chain         string        `json:"chain,omitempty"`
+	TxHash        string        `json:"tx_hash,omitempty"`
+	Error         string        `json:"error,omitempty"`
+	ErrorCategory ErrorCategory `json:"error_category,omitempty"`
+	Timestamp     time.Time     `json:"timestamp"`
+}
+
+func historyKey(key string) string {
+	return hashTagged(historyPrefix, key)
+}
+
+func lastTxHash(txHashes []TxHashEntry) *TxHashEntry {
+	if len(txHashes) == 0 {
+		return nil
+	}
+
+	return &txHashes[len(txHashes)-1]
+}
+
+// appendHistory queues the append of e to the history of the ticket stored
+// at key. The history expires along with the ticket: an expiry of 0 makes it
+// persistent.
+func appendHistory(ctx context.Context, pipe redis.Pipeliner, key string, e TicketHistoryEntry, expiry time.Duration) {
+	hKey := historyKey(key)
+
+	pipe.XAdd(ctx, &redis.XAddArgs{
+		Stream: hKey,
+		Values: map[string]interface{}{
+			"status":         string(e.Status),
+			"height":         e.Height,
+			"chain":          e.Chain,
+			"tx_hash":        e.TxHash,
+			"error":          e.Error,
+			"error_category": string(e.ErrorCategory),
+			"timestamp":      e.Timestamp.UTC().Format(time.RFC3339Nano),
+		},
+	})
+
+	if expiry > 0 {
+		pipe.Expire(ctx, hKey, expiry)
+	} else {
+		pipe.Persist(ctx, hKey)
+	}
+}
+
+// TicketHistory returns the status changes of the ticket stored at key, from
+// the oldest to the most recent.
+func (s *Store) TicketHistory(key string) ([]TicketHistoryEntry, error) {
+	return s.TicketHistoryContext(context.Background(), key)
+}
+
+// TicketHistoryContext is like TicketHistory, with a context.
+func (s *Store) TicketHistoryContext(ctx context.Context, key string) ([]TicketHistoryEntry, error) {
+	msgs, err := s.Client.XRange(ctx, historyKey(key), "-", "+").Result()
+	if err != nil {
+		return nil, fmt.Errorf("cannot read ticket history, %w", err)
+	}
+
+	entries := make([]TicketHistoryEntry, 0, len(msgs))
+	for _, msg := range msgs {
+		e, err := parseHistoryEntry(msg)
+		if err != nil {
+			return nil, fmt.Errorf("malformed history entry %s for %s, %w", msg.ID, key, err)
+		}
+
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
+
+func parseHistoryEntry(msg redis.XMessage) (TicketHistoryEntry, error) {
+	e := TicketHistoryEntry{
+		ID:            msg.ID,
+		Status:        TicketStatus(streamValue(msg, "status")),
+		Chain:         streamValue(msg, "chain"),
+		TxHash:        streamValue(msg, "tx_hash"),
+		Error:         streamValue(msg, "error"),
+		ErrorCategory: ErrorCategory(streamValue(msg, "error_category")),
+	}
+
+	if h := streamValue(msg, "height"); h != "" {
+		height, err := strconv.ParseInt(h, 10, 64)
+		if err != nil {
+			return TicketHistoryEntry{}, err
+		}
+		e.Height = height
+	}
+
+	if ts := streamValue(msg, "timestamp"); ts != "" {
+		t, err := time.Parse(time.RFC3339Nano, ts)
+		if err != nil {
+			return TicketHistoryEntry{}, err
+		}
+		e.Timestamp = t
+	}
+
+	return e, nil
+}
+
+func streamValue(msg redis.XMessage, field string) string {
+	v, _ := msg.Values[field].(string)
+	return v
+}
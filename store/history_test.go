@@ -0,0 +1,64 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTicketHistory(t *testing.T) {
+	defer ResetTestStore(mr, store)
+	key := GetKey(testChain, testTxHash)
+	// no history for unknown tickets
+	history, err := store.TicketHistory(key)
+	require.NoError(t, err)
+	require.Empty(t, history)
+
+	start := time.Now()
+	require.NoError(t, store.CreateTicket(testChain, testTxHash, testOwner))
+	require.Equal(t, time.Duration(0), mr.TTL(historyKey(key)))
+	require.NoError(t, store.SetInTransit(key, testDestChain, testSrcChannel,
+		testPktSeq, "sendTx", testChain, 123))
+	require.Equal(t, mr.TTL(key), mr.TTL(historyKey(key)))
+	newKey := GetIBCKey(testDestChain, testSrcChannel, testPktSeq)
+	require.NoError(t, store.SetIbcFailed(newKey, "recvTx", testDestChain, 144))
+	require.Equal(t, time.Duration(0), mr.TTL(historyKey(key)))
+	require.NoError(t, store.SetIbcAckUnlock(newKey, "ackTx", testChain, 150))
+
+	history, err = store.TicketHistory(key)
+	require.NoError(t, err)
+	require.Len(t, history, 4)
+
+	expected := []TicketHistoryEntry{
+		{Status: StatusPending, Chain: testChain, TxHash: testTxHash},
+		{Status: StatusTransit, Chain: testChain, TxHash: "sendTx", Height: 123},
+		{Status: StatusIBCReceiveFailed, Chain: testDestChain, TxHash: "recvTx", Height: 144},
+		{Status: StatusTokensUnlockedAck, Chain: testChain, TxHash: "ackTx", Height: 150},
+	}
+	for i, e := range expected {
+		require.NotEmpty(t, history[i].ID)
+		require.False(t, history[i].Timestamp.Before(start.Truncate(time.Second)))
+		history[i].ID = ""
+		history[i].Timestamp = time.Time{}
+		require.Equal(t, e, history[i])
+	}
+
+	// history retention follows the ticket one
+	require.Equal(t, mr.TTL(key), mr.TTL(historyKey(key)))
+	require.NotZero(t, mr.TTL(key))
+}
+
+func TestTicketHistoryError(t *testing.T) {
+	defer ResetTestStore(mr, store)
+	key := GetKey(testChain, testTxHash)
+	require.NoError(t, store.CreateTicket(testChain, testTxHash, testOwner))
+	require.NoError(t, store.SetFailedWithErr(key, testErr, 123))
+
+	history, err := store.TicketHistory(key)
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	require.Equal(t, StatusFailed, history[1].Status)
+	require.Equal(t, testErr, history[1].Error)
+	require.EqualValues(t, 123, history[1].Height)
+}
@@ -0,0 +1,185 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidHop is returned when a packet doesn't match the route of the
+// ticket it belongs to.
+var ErrInvalidHop = errors.New("invalid ticket hop")
+
+// A ticket sent over IBC declares its route, the chains the tokens go
+// through up to their final destination, and records a Hop for every packet
+// sent along it. Each packet key holds a ticket whose Info is the key of the
+// ticket it belongs to.
+// The ticket stays in transit until the packet of the last hop is received,
+// and moves to the status of any packet that fails.
+// Tickets without hops, sent before routes were recorded, take the status of
+// their single packet.
+
+// Hop is a packet sent along the route of a ticket.
+type Hop struct {
+	// Chain is the chain sending the packet.
+	Chain     string        `json:"chain"`
+	DestChain string        `json:"dest_chain"`
+	Channel   string        `json:"channel"`
+	Sequence  string        `json:"sequence"`
+	PacketKey string        `json:"packet_key"`
+	Status    TicketStatus  `json:"status"`
+	TxHashes  []TxHashEntry `json:"tx_hashes,omitempty"`
+}
+
+// hopIndex returns the index of the hop of t whose packet is stored at
+// packetKey, or -1.
+func hopIndex(t Ticket, packetKey string) int {
+	for i, h := range t.Hops {
+		if h.PacketKey == packetKey {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// hopsTxHashes returns the tx hashes of every hop, in order.
+func hopsTxHashes(hops []Hop) []TxHashEntry {
+	var txHashes []TxHashEntry
+	for _, h := range hops {
+		txHashes = append(txHashes, h.TxHashes...)
+	}
+
+	return txHashes
+}
+
+// packetTicket is the ticket stored at the packet key of a hop.
+func packetTicket(key, owner string, sendTx TxHashEntry) Ticket {
+	return Ticket{
+		Info:     key,
+		Owner:    owner,
+		TxHashes: []TxHashEntry{sendTx},
+	}
+}
+
+// sendHop returns the hop of a packet sent by sendTx.
+func sendHop(destChain, channel, sequence string, sendTx TxHashEntry) Hop {
+	return Hop{
+		Chain:     sendTx.Chain,
+		DestChain: destChain,
+		Channel:   channel,
+		Sequence:  sequence,
+		PacketKey: GetIBCKey(destChain, channel, sequence),
+		Status:    StatusTransit,
+		TxHashes:  []TxHashEntry{sendTx},
+	}
+}
+
+func firstChain(route []string) string {
+	if len(route) == 0 {
+		return ""
+	}
+
+	return route[0]
+}
+
+// inTransitTransition moves the ticket stored at key in transit along route,
+// with the packet of the first hop.
+func inTransitTransition(key string, route []string, hop Hop, height int64) (ticketTransition, error) {
+	if len(route) == 0 || route[0] != hop.DestChain {
+		return ticketTransition{}, fmt.Errorf("%w: %s is not the first chain of route %v", ErrInvalidHop, hop.DestChain, route)
+	}
+
+	return ticketTransition{
+		key:  key,
+		next: StatusTransit,
+		tx:   &hop.TxHashes[0],
+		update: func(t *Ticket) {
+			t.Height = height
+			t.Route = route
+			t.Hops = []Hop{hop}
+			t.TxHashes = hopsTxHashes(t.Hops)
+		},
+	}, nil
+}
+
+// addHopTransition appends hop to the ticket the packet stored at prevKey
+// belongs to.
+func addHopTransition(prevKey string, prev Ticket, hop Hop, height int64) ticketTransition {
+	return ticketTransition{
+		key: prev.Info,
+		tx:  &hop.TxHashes[0],
+		resolve: func(t Ticket) (TicketStatus, error) {
+			if t.Status != StatusTransit {
+				return "", fmt.Errorf("%w: %s is %s", ErrInvalidTransition, prev.Info, t.Status)
+			}
+
+			i := hopIndex(t, prevKey)
+			switch {
+			case i < 0:
+				return "", fmt.Errorf("%w: %s is not a hop of %s", ErrInvalidHop, prevKey, prev.Info)
+			case i != len(t.Hops)-1:
+				return "", fmt.Errorf("%w: %s is already followed by another hop", ErrInvalidHop, prevKey)
+			case i+1 >= len(t.Route) || t.Route[i+1] != hop.DestChain:
+				return "", fmt.Errorf("%w: %s is not the next chain of route %v", ErrInvalidHop, hop.DestChain, t.Route)
+			}
+
+			return "", nil
+		},
+		update: func(t *Ticket) {
+			t.Hops = append(append([]Hop(nil), t.Hops...), hop)
+			t.TxHashes = hopsTxHashes(t.Hops)
+			t.Height = height
+		},
+	}
+}
+
+// hopResultTransition applies the outcome of the packet stored at packetKey,
+// recorded by tx, to the ticket it belongs to.
+func hopResultTransition(packetKey string, packet Ticket, tx TxHashEntry, height int64) ticketTransition {
+	status := tx.Status
+	txHashes := append(packet.TxHashes, tx)
+
+	tr := ticketTransition{
+		key:  packet.Info,
+		next: status,
+		tx:   lastTxHash(txHashes),
+	}
+
+	tr.resolve = func(t Ticket) (TicketStatus, error) {
+		if len(t.Hops) == 0 {
+			return status, nil
+		}
+
+		i := hopIndex(t, packetKey)
+		if i < 0 {
+			return "", fmt.Errorf("%w: %s is not a hop of %s", ErrInvalidHop, packetKey, packet.Info)
+		}
+
+		if status == StatusIBCReceiveSuccess && i < len(t.Route)-1 {
+			// the tokens are forwarded to the next chain
+			return "", nil
+		}
+
+		return status, nil
+	}
+
+	tr.update = func(t *Ticket) {
+		if status != StatusIBCReceiveFailed && packet.Owner != "" {
+			t.Owner = packet.Owner
+		}
+		t.Height = height
+
+		i := hopIndex(*t, packetKey)
+		if i < 0 {
+			t.TxHashes = txHashes
+			return
+		}
+
+		t.Hops = append([]Hop(nil), t.Hops...)
+		t.Hops[i].Status = status
+		t.Hops[i].TxHashes = txHashes
+		t.TxHashes = hopsTxHashes(t.Hops)
+	}
+
+	return tr
+}
@@ -0,0 +1,259 @@
+// Package httpapi exposes the tickets of a store.TicketStore over HTTP, as
+// gin routes.
+package httpapi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"go.uber.org/zap"
+
+	"github.com/emerishq/emeris-utils/logging"
+	"github.com/emerishq/emeris-utils/sentryx"
+	"github.com/emerishq/emeris-utils/store"
+)
+
+const (
+	TicketRoute        = "/tickets/:chain/:txhash"
+	TicketHistoryRoute = "/tickets/:chain/:txhash/history"
+	UserTicketsRoute   = "/users/:address/tickets"
+	PacketRoute        = "/packets/:chain/:channel/:sequence"
+)
+
+// Error is the body of the responses of failed requests.
+type Error struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+	// CorrelationID identifies the request in the logs.
+	CorrelationID string `json:"correlation_id,omitempty"`
+}
+
+// TicketResponse is the body of the ticket and packet lookups.
+type TicketResponse struct {
+	Key    string       `json:"key"`
+	Ticket store.Ticket `json:"ticket"`
+}
+
+// UserTicket is an entry of a UserTicketsResponse. Error is set, and Ticket
+// is empty, when the entry can't be resolved to a ticket.
+type UserTicket struct {
+	Key    string        `json:"key"`
+	Chain  string        `json:"chain"`
+	TxHash string        `json:"tx_hash"`
+	Ticket *store.Ticket `json:"ticket,omitempty"`
+	Error  string        `json:"error,omitempty"`
+}
+
+// UserTicketsResponse is the body of the user tickets listing.
+type UserTicketsResponse struct {
+	Tickets []UserTicket `json:"tickets"`
+	// Cursor must be passed as the cursor query parameter to fetch the next
+	// page, it is 0 on the last page.
+	Cursor uint64 `json:"cursor"`
+}
+
+type ticketParams struct {
+	Chain  string `uri:"chain" binding:"required"`
+	TxHash string `uri:"txhash" binding:"required,alphanum"`
+}
+
+func (p ticketParams) key() string {
+	return store.GetKey(p.Chain, p.TxHash)
+}
+
+type userTicketsParams struct {
+	Address string `uri:"address" binding:"required,alphanum"`
+}
+
+type userTicketsQuery struct {
+	Chains   []string `form:"chain"`
+	Statuses []string `form:"status"`
+	Limit    int64    `form:"limit" binding:"omitempty,min=1,max=1000"`
+	Cursor   uint64   `form:"cursor"`
+}
+
+type packetParams struct {
+	Chain    string `uri:"chain" binding:"required"`
+	Channel  string `uri:"channel" binding:"required"`
+	Sequence string `uri:"sequence" binding:"required,numeric"`
+}
+
+type handler struct {
+	store store.TicketStore
+}
+
+// Register adds the ticket routes to r, along with the sentryx and
+// correlation ID middlewares. The routes log through l.
+func Register(r gin.IRouter, s store.TicketStore, l *zap.SugaredLogger) {
+	h := handler{store: s}
+
+	g := r.Group("", sentryx.GinMiddleware, logging.AddLoggerMiddleware(l))
+	g.GET(TicketRoute, h.ticket)
+	g.GET(TicketHistoryRoute, h.ticketHistory)
+	g.GET(UserTicketsRoute, h.userTickets)
+	g.GET(PacketRoute, h.packet)
+}
+
+func (h handler) ticket(c *gin.Context) {
+	var p ticketParams
+	if err := c.ShouldBindUri(&p); err != nil {
+		writeError(c, http.StatusBadRequest, bindingError(err))
+		return
+	}
+
+	ticket, err := h.store.GetContext(c.Request.Context(), p.key())
+	if err != nil {
+		writeStoreError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, TicketResponse{Key: p.key(), Ticket: ticket})
+}
+
+func (h handler) ticketHistory(c *gin.Context) {
+	var p ticketParams
+	if err := c.ShouldBindUri(&p); err != nil {
+		writeError(c, http.StatusBadRequest, bindingError(err))
+		return
+	}
+
+	history, err := h.store.TicketHistoryContext(c.Request.Context(), p.key())
+	if err != nil {
+		writeStoreError(c, err)
+		return
+	}
+
+	if len(history) == 0 {
+		writeError(c, http.StatusNotFound, errors.New("ticket not found"))
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
+
+func (h handler) userTickets(c *gin.Context) {
+	var p userTicketsParams
+	if err := c.ShouldBindUri(&p); err != nil {
+		writeError(c, http.StatusBadRequest, bindingError(err))
+		return
+	}
+
+	var q userTicketsQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		writeError(c, http.StatusBadRequest, bindingError(err))
+		return
+	}
+
+	filter := store.ListFilter{
+		Chains: q.Chains,
+		Limit:  q.Limit,
+	}
+	for _, s := range q.Statuses {
+		status := store.TicketStatus(s)
+		if !status.IsValid() {
+			writeError(c, http.StatusBadRequest, fmt.Errorf("unknown ticket status %s", s))
+			return
+		}
+
+		filter.Statuses = append(filter.Statuses, status)
+	}
+
+	page, err := h.store.ListUserTicketsContext(c.Request.Context(), p.Address, filter, q.Cursor)
+	if err != nil {
+		writeStoreError(c, err)
+		return
+	}
+
+	res := UserTicketsResponse{
+		Tickets: make([]UserTicket, 0, len(page.Tickets)),
+		Cursor:  page.Cursor,
+	}
+	for _, t := range page.Tickets {
+		ut := UserTicket{
+			Key:    t.Key,
+			Chain:  t.Chain,
+			TxHash: t.TxHash,
+		}
+
+		if t.Err != nil {
+			ut.Error = t.Err.Error()
+		} else {
+			ticket := t.Ticket
+			ut.Ticket = &ticket
+		}
+
+		res.Tickets = append(res.Tickets, ut)
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h handler) packet(c *gin.Context) {
+	var p packetParams
+	if err := c.ShouldBindUri(&p); err != nil {
+		writeError(c, http.StatusBadRequest, bindingError(err))
+		return
+	}
+
+	ctx := c.Request.Context()
+	packet, err := h.store.GetContext(ctx, store.GetIBCKey(p.Chain, p.Channel, p.Sequence))
+	if err != nil {
+		writeStoreError(c, err)
+		return
+	}
+
+	ticket, err := h.store.GetContext(ctx, packet.Info)
+	if err != nil {
+		writeStoreError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, TicketResponse{Key: packet.Info, Ticket: ticket})
+}
+
+// bindingError lists the parameters that failed validation.
+func bindingError(err error) error {
+	var ve validator.ValidationErrors
+	if !errors.As(err, &ve) {
+		return err
+	}
+
+	fields := make([]string, 0, len(ve))
+	for _, e := range ve {
+		fields = append(fields, e.Field())
+	}
+
+	return fmt.Errorf("invalid fields: %s", strings.Join(fields, ","))
+}
+
+func writeStoreError(c *gin.Context, err error) {
+	if errors.Is(err, store.ErrTicketNotFound) {
+		writeError(c, http.StatusNotFound, errors.New("ticket not found"))
+		return
+	}
+
+	if l, lErr := logging.GetLoggerFromContext(c); lErr == nil {
+		l.Errorw("cannot read tickets", "error", err)
+	}
+
+	// store errors are not exposed
+	writeError(c, http.StatusInternalServerError, errors.New("internal error"))
+}
+
+func writeError(c *gin.Context, status int, err error) {
+	e := Error{
+		Status:  status,
+		Message: err.Error(),
+	}
+
+	if id, ok := c.Request.Context().Value(logging.IntCorrelationIDName).(string); ok {
+		e.CorrelationID = id
+	}
+
+	_ = c.Error(err)
+	c.AbortWithStatusJSON(status, e)
+}
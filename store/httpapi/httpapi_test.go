@@ -0,0 +1,116 @@
+package httpapi_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/emerishq/emeris-utils/logging"
+	"github.com/emerishq/emeris-utils/store"
+	"github.com/emerishq/emeris-utils/store/httpapi"
+)
+
+const (
+	testChain      = "cosmos-hub"
+	testTxHash     = "918DC23785CABA3EE4E4A59321E679F8B7A2E27C9DFB165B3B6D22EF23017264"
+	testOwner      = "cosmos1l2lepugxx5heetsl2cs74e2sy0uqxv390as7zw"
+	testDestChain  = "cosmos-hub-2"
+	testSrcChannel = "channel-1"
+	testPktSeq     = "1"
+)
+
+func setup(t *testing.T) (*store.Store, *gin.Engine) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	m, s := store.SetupTestStore()
+	t.Cleanup(m.Close)
+
+	r := gin.New()
+	httpapi.Register(r, s, zap.NewNop().Sugar())
+
+	return s, r
+}
+
+func get(t *testing.T, r *gin.Engine, path string, status int, v interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set(logging.ExternalCorrelationIDName, "test")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	require.Equal(t, status, w.Code, w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), v))
+	return w
+}
+
+func TestTicket(t *testing.T) {
+	s, r := setup(t)
+	require.NoError(t, s.CreateTicket(testChain, testTxHash, testOwner))
+
+	var res httpapi.TicketResponse
+	w := get(t, r, "/tickets/"+testChain+"/"+testTxHash, http.StatusOK, &res)
+	require.Equal(t, store.GetKey(testChain, testTxHash), res.Key)
+	require.Equal(t, store.StatusPending, res.Ticket.Status)
+	require.Equal(t, "test", w.Header().Get(logging.ExternalCorrelationIDName))
+
+	var history []store.TicketHistoryEntry
+	get(t, r, "/tickets/"+testChain+"/"+testTxHash+"/history", http.StatusOK, &history)
+	require.Len(t, history, 1)
+
+	var e httpapi.Error
+	get(t, r, "/tickets/"+testChain+"/UNKNOWN", http.StatusNotFound, &e)
+	require.Equal(t, http.StatusNotFound, e.Status)
+	require.NotEmpty(t, e.CorrelationID)
+
+	get(t, r, "/tickets/"+testChain+"/UNKNOWN/history", http.StatusNotFound, &e)
+	get(t, r, "/tickets/"+testChain+"/not-a-hash", http.StatusBadRequest, &e)
+	require.Contains(t, e.Message, "TxHash")
+}
+
+func TestUserTickets(t *testing.T) {
+	s, r := setup(t)
+	require.NoError(t, s.CreateTicket(testChain, testTxHash, testOwner))
+	require.NoError(t, s.CreateTicket(testDestChain, "OTHER", testOwner))
+	require.NoError(t, s.SetInTransit(store.GetKey(testChain, testTxHash), testDestChain, testSrcChannel, testPktSeq, testTxHash, testChain, 10))
+
+	var res httpapi.UserTicketsResponse
+	get(t, r, "/users/"+testOwner+"/tickets", http.StatusOK, &res)
+	require.Len(t, res.Tickets, 2)
+	require.Zero(t, res.Cursor)
+
+	get(t, r, "/users/"+testOwner+"/tickets?status=transit&chain="+testChain, http.StatusOK, &res)
+	require.Len(t, res.Tickets, 1)
+	require.Equal(t, testTxHash, res.Tickets[0].TxHash)
+	require.Equal(t, store.StatusTransit, res.Tickets[0].Ticket.Status)
+
+	get(t, r, "/users/nobody/tickets", http.StatusOK, &res)
+	require.Empty(t, res.Tickets)
+
+	var e httpapi.Error
+	get(t, r, "/users/"+testOwner+"/tickets?status=unknown", http.StatusBadRequest, &e)
+	get(t, r, "/users/"+testOwner+"/tickets?limit=5000", http.StatusBadRequest, &e)
+	get(t, r, "/users/"+testOwner+"/tickets?cursor=abc", http.StatusBadRequest, &e)
+}
+
+func TestPacket(t *testing.T) {
+	s, r := setup(t)
+	key := store.GetKey(testChain, testTxHash)
+	require.NoError(t, s.CreateTicket(testChain, testTxHash, testOwner))
+	require.NoError(t, s.SetInTransit(key, testDestChain, testSrcChannel, testPktSeq, testTxHash, testChain, 10))
+
+	var res httpapi.TicketResponse
+	get(t, r, "/packets/"+testDestChain+"/"+testSrcChannel+"/"+testPktSeq, http.StatusOK, &res)
+	require.Equal(t, key, res.Key)
+	require.Equal(t, store.StatusTransit, res.Ticket.Status)
+
+	var e httpapi.Error
+	get(t, r, "/packets/"+testDestChain+"/"+testSrcChannel+"/2", http.StatusNotFound, &e)
+	get(t, r, "/packets/"+testDestChain+"/"+testSrcChannel+"/abc", http.StatusBadRequest, &e)
+}
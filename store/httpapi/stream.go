@@ -0,0 +1,462 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+
+	"github.com/emerishq/emeris-utils/logging"
+	"github.com/emerishq/emeris-utils/sentryx"
+	"github.com/emerishq/emeris-utils/store"
+)
+
+const (
+	TicketStreamRoute    = "/users/:address/tickets/stream"
+	TicketWebSocketRoute = "/users/:address/tickets/ws"
+
+	// LastEventIDHeader is sent by SSE clients when reconnecting.
+	LastEventIDHeader = "Last-Event-ID"
+
+	defaultHeartbeat          = 15 * time.Second
+	defaultMaxUserConnections = 5
+	defaultMaxConnections     = 1000
+
+	streamBatchSize = 100
+	wsWriteTimeout  = 10 * time.Second
+)
+
+var eventIDRegexp = regexp.MustCompile(`^\d+-\d+$`)
+
+// EventSource provides the streamed ticket events, it is implemented by
+// *store.Store.
+type EventSource interface {
+	SubscribeOwnerTickets(ctx context.Context) (<-chan store.TicketEvent, error)
+	UserTicketEventsContext(ctx context.Context, user, afterID string, count int64) ([]store.TicketEvent, error)
+}
+
+// StreamOptions configure the streaming routes, zero values select the
+// defaults.
+type StreamOptions struct {
+	// Heartbeat is the interval between keep-alive messages, defaults to 15s.
+	Heartbeat time.Duration
+
+	// MaxUserConnections is the maximum number of streams open for a single
+	// address, defaults to 5.
+	MaxUserConnections int
+
+	// MaxConnections is the maximum number of streams open, defaults to
+	// 1000.
+	MaxConnections int
+
+	// CheckOrigin is used to accept WebSocket handshakes, only same origin
+	// requests are accepted when nil.
+	CheckOrigin func(r *http.Request) bool
+
+	// OwnerFallback must be the one of the streamed store.
+	OwnerFallback store.OwnerFallback
+}
+
+func (o StreamOptions) withDefaults() StreamOptions {
+	if o.Heartbeat <= 0 {
+		o.Heartbeat = defaultHeartbeat
+	}
+
+	if o.MaxUserConnections <= 0 {
+		o.MaxUserConnections = defaultMaxUserConnections
+	}
+
+	if o.MaxConnections <= 0 {
+		o.MaxConnections = defaultMaxConnections
+	}
+
+	return o
+}
+
+type streamQuery struct {
+	LastEventID string `form:"last_event_id"`
+}
+
+type streamer struct {
+	source   EventSource
+	opts     StreamOptions
+	hub      *hub
+	limiter  *limiter
+	upgrader websocket.Upgrader
+}
+
+// RegisterStream adds the routes streaming the ticket events of an address
+// to r, as Server-Sent Events on TicketStreamRoute and as WebSocket JSON
+// messages on TicketWebSocketRoute.
+// Streams resume after the event ID passed in the Last-Event-ID header or the
+// last_event_id query parameter, and start with the events retained by the
+// store otherwise.
+// All streams share a single subscription to the events of the store, held
+// while at least one stream is open. Streams are limited by account: the
+// addresses of an account with different bech32 prefixes share a limit.
+func RegisterStream(r gin.IRouter, s EventSource, l *zap.SugaredLogger, opts StreamOptions) {
+	opts = opts.withDefaults()
+	st := &streamer{
+		source:  s,
+		opts:    opts,
+		hub:     newHub(s),
+		limiter: newLimiter(opts.MaxConnections, opts.MaxUserConnections),
+		upgrader: websocket.Upgrader{
+			CheckOrigin: opts.CheckOrigin,
+		},
+	}
+
+	g := r.Group("", sentryx.GinMiddleware, logging.AddLoggerMiddleware(l))
+	g.GET(TicketStreamRoute, st.sse)
+	g.GET(TicketWebSocketRoute, st.websocket)
+}
+
+// streamWriter sends events to a client.
+type streamWriter interface {
+	events(events []store.TicketEvent) error
+	heartbeat() error
+}
+
+// open validates the request and reserves a connection for it, release must
+// be called once the stream is done. The events of the stream are signaled on
+// wake. Errors are written to c.
+func (st *streamer) open(c *gin.Context) (user, lastID string, wake <-chan struct{}, release func(), ok bool) {
+	var p userTicketsParams
+	if err := c.ShouldBindUri(&p); err != nil {
+		writeError(c, http.StatusBadRequest, bindingError(err))
+		return "", "", nil, nil, false
+	}
+
+	var q streamQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		writeError(c, http.StatusBadRequest, bindingError(err))
+		return "", "", nil, nil, false
+	}
+
+	lastID = c.GetHeader(LastEventIDHeader)
+	if lastID == "" {
+		lastID = q.LastEventID
+	}
+
+	if lastID != "" && !eventIDRegexp.MatchString(lastID) {
+		writeError(c, http.StatusBadRequest, fmt.Errorf("invalid last event id %s", lastID))
+		return "", "", nil, nil, false
+	}
+
+	owner, err := store.OwnerKey(p.Address, st.opts.OwnerFallback)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, err)
+		return "", "", nil, nil, false
+	}
+
+	releaseConn, err := st.limiter.acquire(owner)
+	if err != nil {
+		writeError(c, http.StatusTooManyRequests, err)
+		return "", "", nil, nil, false
+	}
+
+	wake, unsubscribe, err := st.hub.subscribe(owner)
+	if err != nil {
+		releaseConn()
+		writeStoreError(c, err)
+		return "", "", nil, nil, false
+	}
+
+	release = func() {
+		unsubscribe()
+		releaseConn()
+	}
+
+	return p.Address, lastID, wake, release, true
+}
+
+// follow writes the events of user following lastID to w, until ctx is done
+// or writing fails.
+// wake only signals new events, which are always read from the store. It is
+// read again on every heartbeat, as events may be published before being
+// stored, and signals may be dropped.
+func (st *streamer) follow(ctx context.Context, wake <-chan struct{}, user, lastID string, w streamWriter) error {
+	ticker := time.NewTicker(st.opts.Heartbeat)
+	defer ticker.Stop()
+
+	for {
+		events, err := st.source.UserTicketEventsContext(ctx, user, lastID, streamBatchSize)
+		if err != nil {
+			return err
+		}
+
+		if len(events) > 0 {
+			if err := w.events(events); err != nil {
+				return err
+			}
+
+			lastID = events[len(events)-1].ID
+		}
+
+		if len(events) == streamBatchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-wake:
+		case <-ticker.C:
+			if err := w.heartbeat(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (st *streamer) sse(c *gin.Context) {
+	user, lastID, wake, release, ok := st.open(c)
+	if !ok {
+		return
+	}
+	defer release()
+
+	ctx := c.Request.Context()
+	h := c.Writer.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	// disables the buffering of nginx proxies
+	h.Set("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	err := st.follow(ctx, wake, user, lastID, sseWriter{w: c.Writer})
+	logStreamError(ctx, c, err)
+}
+
+func (st *streamer) websocket(c *gin.Context) {
+	user, lastID, wake, release, ok := st.open(c)
+	if !ok {
+		return
+	}
+	defer release()
+
+	// hijacked connections don't cancel the request context when closed
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	conn, err := st.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// the upgrader replied with the error
+		return
+	}
+	defer conn.Close()
+
+	// clients answer pings, and are dropped when missing two of them
+	deadline := func() error {
+		return conn.SetReadDeadline(time.Now().Add(2 * st.opts.Heartbeat))
+	}
+	_ = deadline()
+	conn.SetPongHandler(func(string) error { return deadline() })
+
+	// client messages are discarded, reading only handles control frames
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	err = st.follow(ctx, wake, user, lastID, wsWriter{conn: conn})
+	logStreamError(ctx, c, err)
+}
+
+func logStreamError(ctx context.Context, c *gin.Context, err error) {
+	// streams end with their client
+	if err == nil || ctx.Err() != nil {
+		return
+	}
+
+	if l, lErr := logging.GetLoggerFromContext(c); lErr == nil {
+		l.Warnw("ticket stream failed", "error", err)
+	}
+}
+
+type sseWriter struct {
+	w gin.ResponseWriter
+}
+
+func (s sseWriter) events(events []store.TicketEvent) error {
+	for _, e := range events {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+
+		if _, err := fmt.Fprintf(s.w, "id: %s\nevent: ticket\ndata: %s\n\n", e.ID, data); err != nil {
+			return err
+		}
+	}
+
+	s.w.Flush()
+	return nil
+}
+
+func (s sseWriter) heartbeat() error {
+	if _, err := fmt.Fprint(s.w, ": heartbeat\n\n"); err != nil {
+		return err
+	}
+
+	s.w.Flush()
+	return nil
+}
+
+type wsWriter struct {
+	conn *websocket.Conn
+}
+
+func (w wsWriter) events(events []store.TicketEvent) error {
+	for _, e := range events {
+		_ = w.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+		if err := w.conn.WriteJSON(e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w wsWriter) heartbeat() error {
+	return w.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout))
+}
+
+// limiter counts the open streams, in total and by owner key.
+type limiter struct {
+	mu      sync.Mutex
+	max     int
+	maxUser int
+	total   int
+	users   map[string]int
+}
+
+func newLimiter(max, maxUser int) *limiter {
+	return &limiter{
+		max:     max,
+		maxUser: maxUser,
+		users:   make(map[string]int),
+	}
+}
+
+func (l *limiter) acquire(user string) (func(), error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.total >= l.max {
+		return nil, errors.New("too many open streams")
+	}
+
+	if l.users[user] >= l.maxUser {
+		return nil, errors.New("too many open streams for this address")
+	}
+
+	l.total++
+	l.users[user]++
+
+	return func() { l.release(user) }, nil
+}
+
+func (l *limiter) release(user string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.total--
+	l.users[user]--
+	if l.users[user] == 0 {
+		delete(l.users, user)
+	}
+}
+
+// hub shares a subscription to the ticket events of a source between the
+// open streams, and signals every stream of the owner of an event. The
+// subscription is held while at least one stream is open.
+type hub struct {
+	source EventSource
+
+	mu      sync.Mutex
+	streams map[string]map[chan struct{}]struct{}
+	open    int
+	cancel  context.CancelFunc
+}
+
+func newHub(source EventSource) *hub {
+	return &hub{
+		source:  source,
+		streams: make(map[string]map[chan struct{}]struct{}),
+	}
+}
+
+// subscribe returns a channel signaling the events of owner, until
+// unsubscribe is called.
+func (h *hub) subscribe(owner string) (wake <-chan struct{}, unsubscribe func(), err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.open == 0 {
+		ctx, cancel := context.WithCancel(context.Background())
+		events, err := h.source.SubscribeOwnerTickets(ctx)
+		if err != nil {
+			cancel()
+			return nil, nil, err
+		}
+
+		h.cancel = cancel
+		go h.dispatch(events)
+	}
+
+	// a single pending signal is enough, events are read from the store
+	w := make(chan struct{}, 1)
+	if h.streams[owner] == nil {
+		h.streams[owner] = make(map[chan struct{}]struct{})
+	}
+	h.streams[owner][w] = struct{}{}
+	h.open++
+
+	return w, func() { h.unsubscribe(owner, w) }, nil
+}
+
+func (h *hub) unsubscribe(owner string, w chan struct{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.streams[owner], w)
+	if len(h.streams[owner]) == 0 {
+		delete(h.streams, owner)
+	}
+
+	h.open--
+	if h.open == 0 {
+		h.cancel()
+		h.cancel = nil
+	}
+}
+
+// dispatch signals the streams of the owner of every event, without waiting
+// for them.
+func (h *hub) dispatch(events <-chan store.TicketEvent) {
+	for e := range events {
+		h.mu.Lock()
+		for w := range h.streams[e.Owner] {
+			select {
+			case w <- struct{}{}:
+			default:
+			}
+		}
+		h.mu.Unlock()
+	}
+}
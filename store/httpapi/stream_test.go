@@ -0,0 +1,193 @@
+package httpapi_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/emerishq/emeris-utils/store"
+	"github.com/emerishq/emeris-utils/store/httpapi"
+)
+
+// testOsmoOwner is the address of testOwner with another prefix.
+const testOsmoOwner = "osmo1l2lepugxx5heetsl2cs74e2sy0uqxv398xrw5u"
+
+func setupStream(t *testing.T) (*miniredis.Miniredis, *store.Store, *httptest.Server) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	m, s := store.SetupTestStore()
+	t.Cleanup(m.Close)
+
+	r := gin.New()
+	httpapi.RegisterStream(r, s, zap.NewNop().Sugar(), httpapi.StreamOptions{
+		Heartbeat:          50 * time.Millisecond,
+		MaxUserConnections: 1,
+	})
+
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+
+	return m, s, srv
+}
+
+type sseClient struct {
+	r *bufio.Reader
+}
+
+func openSSE(ctx context.Context, t *testing.T, url, lastID string) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	require.NoError(t, err)
+	if lastID != "" {
+		req.Header.Set(httpapi.LastEventIDHeader, lastID)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = res.Body.Close() })
+
+	return res
+}
+
+// next returns the next event, skipping heartbeats.
+func (c sseClient) next(t *testing.T) (string, store.TicketEvent) {
+	t.Helper()
+
+	for {
+		var id, data string
+		heartbeat := false
+		for {
+			line, err := c.r.ReadString('\n')
+			require.NoError(t, err)
+
+			line = strings.TrimSuffix(line, "\n")
+			if line == "" {
+				break
+			}
+
+			switch {
+			case strings.HasPrefix(line, ":"):
+				heartbeat = true
+			case strings.HasPrefix(line, "id: "):
+				id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "data: "):
+				data = strings.TrimPrefix(line, "data: ")
+			}
+		}
+
+		if heartbeat {
+			continue
+		}
+
+		var e store.TicketEvent
+		require.NoError(t, json.Unmarshal([]byte(data), &e))
+		require.Equal(t, id, e.ID)
+		return id, e
+	}
+}
+
+func TestStreamSSE(t *testing.T) {
+	m, s, srv := setupStream(t)
+	key := store.GetKey(testChain, testTxHash)
+	url := srv.URL + "/users/" + testOwner + "/tickets/stream"
+	require.NoError(t, s.CreateTicket(testChain, testTxHash, testOwner))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	res := openSSE(ctx, t, url, "")
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+	c := sseClient{r: bufio.NewReader(res.Body)}
+
+	// retained events are replayed
+	pendingID, e := c.next(t)
+	require.Equal(t, key, e.Key)
+	require.Equal(t, store.StatusPending, e.NewStatus)
+
+	require.NoError(t, s.SetInTransit(key, testDestChain, testSrcChannel, testPktSeq, testTxHash, testChain, 10))
+	_, e = c.next(t)
+	require.Equal(t, store.StatusTransit, e.NewStatus)
+
+	// addresses of the same account share a limit
+	var errRes httpapi.Error
+	other := openSSE(context.Background(), t, srv.URL+"/users/"+testOsmoOwner+"/tickets/stream", "")
+	require.Equal(t, http.StatusTooManyRequests, other.StatusCode)
+	require.NoError(t, json.NewDecoder(other.Body).Decode(&errRes))
+	require.Contains(t, errRes.Message, "too many open streams")
+
+	// streams share a single subscription, released with the last one
+	otherCtx, otherCancel := context.WithCancel(context.Background())
+	other = openSSE(otherCtx, t, srv.URL+"/users/someone/tickets/stream", "")
+	require.Equal(t, http.StatusOK, other.StatusCode)
+	require.Equal(t, 1, m.PubSubNumPat())
+
+	// the connection is released once the client is gone
+	cancel()
+	otherCancel()
+	require.Eventually(t, func() bool {
+		return m.PubSubNumPat() == 0
+	}, 5*time.Second, 50*time.Millisecond)
+	require.Eventually(t, func() bool {
+		res = openSSE(context.Background(), t, url, pendingID)
+		return res.StatusCode == http.StatusOK
+	}, 5*time.Second, 50*time.Millisecond)
+
+	c = sseClient{r: bufio.NewReader(res.Body)}
+	_, e = c.next(t)
+	require.Equal(t, store.StatusTransit, e.NewStatus)
+}
+
+func TestStreamSSEInvalidLastEventID(t *testing.T) {
+	_, _, srv := setupStream(t)
+
+	res := openSSE(context.Background(), t, srv.URL+"/users/"+testOwner+"/tickets/stream?last_event_id=abc", "")
+	require.Equal(t, http.StatusBadRequest, res.StatusCode)
+}
+
+func TestStreamWebSocket(t *testing.T) {
+	_, s, srv := setupStream(t)
+	key := store.GetKey(testChain, testTxHash)
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/users/" + testOwner + "/tickets/ws"
+	require.NoError(t, s.CreateTicket(testChain, testTxHash, testOwner))
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	var e store.TicketEvent
+	require.NoError(t, conn.ReadJSON(&e))
+	require.Equal(t, key, e.Key)
+	require.Equal(t, store.StatusPending, e.NewStatus)
+	pendingID := e.ID
+
+	require.NoError(t, s.SetInTransit(key, testDestChain, testSrcChannel, testPktSeq, testTxHash, testChain, 10))
+	require.NoError(t, conn.ReadJSON(&e))
+	require.Equal(t, store.StatusTransit, e.NewStatus)
+
+	osmoURL := "ws" + strings.TrimPrefix(srv.URL, "http") + "/users/" + testOsmoOwner + "/tickets/ws"
+	_, res, err := websocket.DefaultDialer.Dial(osmoURL, nil)
+	require.Error(t, err)
+	require.Equal(t, http.StatusTooManyRequests, res.StatusCode)
+
+	require.NoError(t, conn.Close())
+	require.Eventually(t, func() bool {
+		conn, _, err = websocket.DefaultDialer.Dial(url+"?last_event_id="+pendingID, nil)
+		return err == nil
+	}, 5*time.Second, 50*time.Millisecond)
+	defer conn.Close()
+
+	require.NoError(t, conn.ReadJSON(&e))
+	require.Equal(t, store.StatusTransit, e.NewStatus)
+}
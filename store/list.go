@@ -0,0 +1,200 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const defaultListLimit = 50
+
+var (
+	// ErrMalformedTicketKey is reported for owner set members that are not
+	// chain/txhash ticket keys.
+	ErrMalformedTicketKey = errors.New("malformed ticket key")
+
+	// ErrTicketExpired is reported for owner set members whose ticket no
+	// longer exists.
+	ErrTicketExpired = errors.New("ticket expired")
+)
+
+// ListFilter selects the tickets returned by ListUserTickets.
+// Empty fields match every ticket.
+type ListFilter struct {
+	Chains   []string
+	Statuses []TicketStatus
+
+	// Limit is the minimum number of entries scanned before a page is
+	// returned, defaults to 50. Pages may hold a few more entries, or fewer
+	// when filters are set.
+	Limit int64
+}
+
+func (f ListFilter) matchChain(chain string) bool {
+	if len(f.Chains) == 0 {
+		return true
+	}
+
+	for _, c := range f.Chains {
+		if c == chain {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (f ListFilter) matchStatus(status TicketStatus) bool {
+	if len(f.Statuses) == 0 {
+		return true
+	}
+
+	for _, s := range f.Statuses {
+		if s == status {
+			return true
+		}
+	}
+
+	return false
+}
+
+// UserTicket is an entry of a TicketPage.
+// Err is set, and Ticket is empty, when the entry can't be resolved to a
+// ticket: it wraps ErrMalformedTicketKey, ErrTicketExpired or the error
+// returned while reading the ticket.
+type UserTicket struct {
+	Key    string
+	Chain  string
+	TxHash string
+	Ticket Ticket
+	Err    error
+}
+
+// TicketPage is a page of tickets returned by ListUserTickets.
+type TicketPage struct {
+	Tickets []UserTicket
+	// Cursor must be passed to ListUserTickets to fetch the next page, it is
+	// 0 once every ticket has been returned.
+	Cursor uint64
+}
+
+// ListUserTickets returns the tickets of owner matching filter, starting at
+// cursor: pass 0 to get the first page.
+// Entries that can't be resolved are returned with their Err set instead of
+// failing the whole call; the status filter is not applied to them, nor is
+// the chain filter when their key is malformed.
+// Tickets are not sorted, and a ticket created or removed while paging may
+// or may not be returned.
+func (s *Store) ListUserTickets(owner string, filter ListFilter, cursor uint64) (TicketPage, error) {
+	return s.ListUserTicketsContext(context.Background(), owner, filter, cursor)
+}
+
+// ListUserTicketsContext is like ListUserTickets, with a context.
+func (s *Store) ListUserTicketsContext(ctx context.Context, owner string, filter ListFilter, cursor uint64) (TicketPage, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+
+	ownerKey, err := s.ownerKey(owner)
+	if err != nil {
+		return TicketPage{}, err
+	}
+
+	var (
+		keys    []string
+		scanned int64
+	)
+	for {
+		batch, next, err := s.Client.SScan(ctx, ownerKey, cursor, "", limit-scanned).Result()
+		if err != nil {
+			return TicketPage{}, fmt.Errorf("cannot scan tickets of %s, %w", owner, err)
+		}
+
+		keys = append(keys, batch...)
+		scanned += int64(len(batch))
+		cursor = next
+
+		if cursor == 0 || scanned >= limit {
+			break
+		}
+	}
+
+	page := TicketPage{Cursor: cursor}
+	failed, pending := userTicketEntries(keys, filter)
+	page.Tickets = append(page.Tickets, failed...)
+
+	if len(pending) == 0 {
+		return page, nil
+	}
+
+	keys = make([]string, len(pending))
+	for i, t := range pending {
+		keys[i] = t.Key
+	}
+
+	values, _, err := getMany(ctx, s.Client, keys)
+	if err != nil {
+		return TicketPage{}, fmt.Errorf("cannot read tickets of %s, %w", owner, err)
+	}
+
+	for _, t := range pending {
+		// tickets expiring between SSCAN and GET are reported as expired
+		var (
+			ticket Ticket
+			err    error = redis.Nil
+		)
+		if v, ok := values[t.Key]; ok {
+			err = ticket.UnmarshalBinary([]byte(v))
+		}
+
+		if t, ok := resolveUserTicket(t, ticket, err, filter); ok {
+			page.Tickets = append(page.Tickets, t)
+		}
+	}
+
+	return page, nil
+}
+
+// userTicketEntries splits the owner set members keys between the entries
+// that can't be resolved, and the ones matching the chain filter whose
+// ticket must be read.
+func userTicketEntries(keys []string, filter ListFilter) (failed, pending []UserTicket) {
+	for _, key := range keys {
+		t := UserTicket{Key: key}
+
+		parts := strings.Split(key, "/")
+		if len(parts) != 2 {
+			t.Err = fmt.Errorf("%w: %s", ErrMalformedTicketKey, key)
+			failed = append(failed, t)
+			continue
+		}
+
+		t.Chain, t.TxHash = parts[0], parts[1]
+		if filter.matchChain(t.Chain) {
+			pending = append(pending, t)
+		}
+	}
+
+	return failed, pending
+}
+
+// resolveUserTicket completes t with the outcome of reading its ticket.
+// It returns false if the ticket doesn't match the status filter.
+func resolveUserTicket(t UserTicket, ticket Ticket, err error, filter ListFilter) (UserTicket, bool) {
+	switch {
+	case errors.Is(err, redis.Nil):
+		t.Err = fmt.Errorf("%w: %s", ErrTicketExpired, t.Key)
+	case err != nil:
+		t.Err = fmt.Errorf("cannot decode ticket %s, %w", t.Key, err)
+	case !filter.matchStatus(ticket.Status):
+		return UserTicket{}, false
+	default:
+		t.Ticket = ticket
+	}
+
+	return t, true
+}
@@ -0,0 +1,102 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestListUserTickets(t *testing.T) {
+	defer ResetTestStore(mr, store)
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, store.CreateTicket(testChain, fmt.Sprintf("HASH%d", i), testOwner))
+	}
+	require.NoError(t, store.CreateTicket(testDestChain, "OTHER", testOwner))
+	require.NoError(t, store.SetComplete(GetKey(testChain, "HASH0"), 12))
+	require.NoError(t, store.SetInTransit(GetKey(testChain, "HASH1"), testDestChain, testSrcChannel, testPktSeq, testTxHash, testChain, 12))
+
+	// entries that can't be resolved to a ticket
+	ownerKey, err := OwnerKey(testOwner, nil)
+	require.NoError(t, err)
+	require.NoError(t, store.Client.SAdd(context.Background(), ownerKey, "malformed").Err())
+	require.NoError(t, store.Client.SAdd(context.Background(), ownerKey, GetKey(testChain, "EXPIRED")).Err())
+
+	t.Run("all", func(t *testing.T) {
+		page, err := store.ListUserTickets(testOwner, ListFilter{}, 0)
+		require.NoError(t, err)
+		require.Zero(t, page.Cursor)
+		// the complete ticket is no longer owned
+		require.Len(t, page.Tickets, 7)
+
+		var failed int
+		for _, ticket := range page.Tickets {
+			switch {
+			case errors.Is(ticket.Err, ErrMalformedTicketKey):
+				require.Equal(t, "malformed", ticket.Key)
+				failed++
+			case errors.Is(ticket.Err, ErrTicketExpired):
+				require.Equal(t, "EXPIRED", ticket.TxHash)
+				failed++
+			default:
+				require.NoError(t, ticket.Err)
+				require.Equal(t, GetKey(ticket.Chain, ticket.TxHash), ticket.Key)
+				require.NotEmpty(t, ticket.Ticket.Status)
+			}
+		}
+		require.Equal(t, 2, failed)
+	})
+
+	t.Run("filters", func(t *testing.T) {
+		page, err := store.ListUserTickets(testOwner, ListFilter{
+			Chains:   []string{testDestChain},
+			Statuses: []TicketStatus{StatusPending},
+		}, 0)
+		require.NoError(t, err)
+		// the malformed entry is always reported
+		require.Len(t, page.Tickets, 2)
+
+		page, err = store.ListUserTickets(testOwner, ListFilter{
+			Chains:   []string{testChain},
+			Statuses: []TicketStatus{StatusTransit},
+		}, 0)
+		require.NoError(t, err)
+		var found bool
+		for _, ticket := range page.Tickets {
+			if ticket.Err == nil {
+				require.Equal(t, "HASH1", ticket.TxHash)
+				require.Equal(t, StatusTransit, ticket.Ticket.Status)
+				found = true
+			}
+		}
+		require.True(t, found)
+	})
+
+	t.Run("pagination", func(t *testing.T) {
+		seen := map[string]bool{}
+		var cursor uint64
+		for {
+			page, err := store.ListUserTickets(testOwner, ListFilter{Limit: 2}, cursor)
+			require.NoError(t, err)
+			for _, ticket := range page.Tickets {
+				seen[ticket.Key] = true
+			}
+
+			cursor = page.Cursor
+			if cursor == 0 {
+				break
+			}
+		}
+		require.Len(t, seen, 7)
+	})
+
+	t.Run("unknown owner", func(t *testing.T) {
+		page, err := store.ListUserTickets("nobody", ListFilter{}, 0)
+		require.NoError(t, err)
+		require.Empty(t, page.Tickets)
+		require.Zero(t, page.Cursor)
+	})
+}
@@ -0,0 +1,296 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/go-redis/redis/v8"
+	"github.com/gofrs/uuid"
+)
+
+const (
+	lockPrefix = "lock"
+
+	defaultLockTTL   = 10 * time.Second
+	lockPollInterval = 50 * time.Millisecond
+)
+
+// releaseLock deletes a lock only if it's still held by the caller.
+var releaseLock = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("del", KEYS[1])
+end
+return 0
+`)
+
+// errLoadPanicked is returned to the callers waiting for a load that
+// panicked.
+var errLoadPanicked = errors.New("cache loader panicked")
+
+// Loader returns the value of a cache entry, which is then stored as JSON.
+type Loader func(ctx context.Context) (interface{}, error)
+
+// LoadOptions configures GetOrLoadWithOptions.
+type LoadOptions struct {
+	// TTL is how long a loaded value is fresh.
+	TTL time.Duration
+	// StaleTTL is how long a value is kept once it's no longer fresh. Stale
+	// values are returned right away while a single caller refreshes them in
+	// the background.
+	StaleTTL time.Duration
+	// LockTTL bounds the time a load holds the lock preventing other
+	// processes from loading the same key, defaults to 10 seconds. Callers
+	// waiting for the lock give up after LockTTL and load the value
+	// themselves.
+	LockTTL time.Duration
+	// OnRefreshError, if not nil, is called with the errors met while
+	// refreshing a stale value in the background, a panicking loader
+	// included. The value stays stale until the next refresh.
+	OnRefreshError func(key string, err error)
+}
+
+type cacheEntry struct {
+	Value      json.RawMessage `json:"value"`
+	FreshUntil time.Time       `json:"fresh_until"`
+}
+
+// GetOrLoad decodes the JSON value cached at key into dst, a pointer.
+// On a miss, the value is returned by loader and cached for ttl.
+// Concurrent misses on the same key call loader only once per process, and,
+// through a lock stored in redis, once across processes. Within a process,
+// loader is called with the context of the first caller.
+func (s *Store) GetOrLoad(ctx context.Context, key string, ttl time.Duration, dst interface{}, loader Loader) error {
+	return s.GetOrLoadWithOptions(ctx, key, dst, loader, LoadOptions{TTL: ttl})
+}
+
+// GetOrLoadWithOptions is like GetOrLoad, with options.
+func (s *Store) GetOrLoadWithOptions(ctx context.Context, key string, dst interface{}, loader Loader, opts LoadOptions) error {
+	if opts.TTL <= 0 {
+		return fmt.Errorf("invalid cache ttl %s", opts.TTL)
+	}
+	if opts.LockTTL <= 0 {
+		opts.LockTTL = defaultLockTTL
+	}
+
+	entry, err := s.getCacheEntry(ctx, key)
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return err
+	}
+
+	if err == nil {
+		if time.Now().After(entry.FreshUntil) {
+			go s.refresh(key, loader, opts)
+		}
+
+		return decodeCacheEntry(key, entry, dst)
+	}
+
+	value, err := s.loads.do(key, func() (json.RawMessage, error) {
+		return s.loadLocked(ctx, key, loader, opts)
+	})
+	if err != nil {
+		return err
+	}
+
+	return decodeCacheEntry(key, cacheEntry{Value: value}, dst)
+}
+
+// refresh reloads a stale value in the background, unless another caller
+// already does.
+// Callers already got the stale value, so loader panics are recovered and
+// reported to sentry rather than crashing the process.
+func (s *Store) refresh(key string, loader Loader, opts LoadOptions) {
+	ctx, cancel := context.WithTimeout(context.Background(), opts.LockTTL)
+	defer cancel()
+
+	defer func() {
+		if r := recover(); r != nil {
+			sentry.CurrentHub().Recover(r)
+			if opts.OnRefreshError != nil {
+				opts.OnRefreshError(key, fmt.Errorf("%w: %v", errLoadPanicked, r))
+			}
+		}
+	}()
+
+	_, err := s.refreshes.do(key, func() (json.RawMessage, error) {
+		token, ok, err := s.acquireLock(ctx, key, opts.LockTTL)
+		if err != nil || !ok {
+			return nil, err
+		}
+		defer s.releaseLock(key, token)
+
+		return s.load(ctx, key, loader, opts)
+	})
+	if err != nil && opts.OnRefreshError != nil {
+		opts.OnRefreshError(key, err)
+	}
+}
+
+// loadLocked loads the value of key while holding its lock, or waits for
+// the process holding the lock to store it.
+func (s *Store) loadLocked(ctx context.Context, key string, loader Loader, opts LoadOptions) (json.RawMessage, error) {
+	deadline := time.Now().Add(opts.LockTTL)
+
+	for {
+		token, ok, err := s.acquireLock(ctx, key, opts.LockTTL)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			defer s.releaseLock(key, token)
+
+			// the value may have been stored since it was first read
+			entry, err := s.getCacheEntry(ctx, key)
+			if err == nil {
+				return entry.Value, nil
+			}
+
+			return s.load(ctx, key, loader, opts)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(lockPollInterval):
+		}
+
+		entry, err := s.getCacheEntry(ctx, key)
+		if err == nil {
+			return entry.Value, nil
+		}
+		if !errors.Is(err, redis.Nil) {
+			return nil, err
+		}
+
+		if time.Now().After(deadline) {
+			// the lock holder is too slow, or gone
+			return s.load(ctx, key, loader, opts)
+		}
+	}
+}
+
+func (s *Store) load(ctx context.Context, key string, loader Loader, opts LoadOptions) (json.RawMessage, error) {
+	v, err := loader(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("cannot encode %s, %w", key, err)
+	}
+
+	bz, err := json.Marshal(cacheEntry{
+		Value:      value,
+		FreshUntil: time.Now().Add(opts.TTL),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot encode %s, %w", key, err)
+	}
+
+	if err := s.Client.Set(ctx, key, bz, opts.TTL+opts.StaleTTL).Err(); err != nil {
+		return nil, fmt.Errorf("cannot cache %s, %w", key, err)
+	}
+
+	return value, nil
+}
+
+func (s *Store) getCacheEntry(ctx context.Context, key string) (cacheEntry, error) {
+	bz, err := s.Client.Get(ctx, key).Bytes()
+	if err != nil {
+		return cacheEntry{}, err
+	}
+
+	var entry cacheEntry
+	if err := json.Unmarshal(bz, &entry); err != nil {
+		return cacheEntry{}, fmt.Errorf("malformed cache entry %s, %w", key, err)
+	}
+
+	return entry, nil
+}
+
+func decodeCacheEntry(key string, entry cacheEntry, dst interface{}) error {
+	if err := json.Unmarshal(entry.Value, dst); err != nil {
+		return fmt.Errorf("cannot decode %s, %w", key, err)
+	}
+
+	return nil
+}
+
+func lockKey(key string) string {
+	return hashTagged(lockPrefix, key)
+}
+
+func (s *Store) acquireLock(ctx context.Context, key string, ttl time.Duration) (string, bool, error) {
+	id, err := uuid.NewV4()
+	if err != nil {
+		return "", false, fmt.Errorf("cannot generate lock token, %w", err)
+	}
+
+	token := id.String()
+	ok, err := s.Client.SetNX(ctx, lockKey(key), token, ttl).Result()
+	if err != nil {
+		return "", false, fmt.Errorf("cannot acquire lock on %s, %w", key, err)
+	}
+
+	return token, ok, nil
+}
+
+func (s *Store) releaseLock(key, token string) {
+	// the lock expires anyway, release it even if the load was canceled
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	_ = releaseLock.Run(ctx, s.Client, []string{lockKey(key)}, token).Err()
+}
+
+// loadGroup deduplicates concurrent loads of the same key within a process.
+type loadGroup struct {
+	mu    sync.Mutex
+	calls map[string]*loadCall
+}
+
+type loadCall struct {
+	wg    sync.WaitGroup
+	value json.RawMessage
+	err   error
+}
+
+// do calls fn, unless a call for key is already in flight, in which case it
+// waits for it and returns its result.
+func (g *loadGroup) do(key string, fn func() (json.RawMessage, error)) (json.RawMessage, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*loadCall)
+	}
+	if c, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		c.wg.Wait()
+		return c.value, c.err
+	}
+
+	c := &loadCall{}
+	c.wg.Add(1)
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	// waiters are released even if fn panics, the panic then propagates to
+	// the caller of fn only
+	c.err = errLoadPanicked
+	defer func() {
+		c.wg.Done()
+
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+	}()
+
+	c.value, c.err = fn()
+
+	return c.value, c.err
+}
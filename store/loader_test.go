@@ -0,0 +1,186 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type loadedValue struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func countingLoader(calls *int32, delay time.Duration) Loader {
+	return func(ctx context.Context) (interface{}, error) {
+		n := atomic.AddInt32(calls, 1)
+		time.Sleep(delay)
+		return loadedValue{Name: "loaded", Count: int(n)}, nil
+	}
+}
+
+func TestGetOrLoad(t *testing.T) {
+	defer ResetTestStore(mr, store)
+	ctx := context.Background()
+	var calls int32
+
+	var v loadedValue
+	require.NoError(t, store.GetOrLoad(ctx, "cached", time.Minute, &v, countingLoader(&calls, 0)))
+	require.Equal(t, loadedValue{Name: "loaded", Count: 1}, v)
+	require.Equal(t, time.Minute, mr.TTL("cached"))
+
+	// hit
+	v = loadedValue{}
+	require.NoError(t, store.GetOrLoad(ctx, "cached", time.Minute, &v, countingLoader(&calls, 0)))
+	require.Equal(t, loadedValue{Name: "loaded", Count: 1}, v)
+	require.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	// the lock is released
+	require.False(t, mr.Exists(lockKey("cached")))
+
+	require.Error(t, store.GetOrLoad(ctx, "cached", 0, &v, countingLoader(&calls, 0)))
+}
+
+func TestGetOrLoadError(t *testing.T) {
+	defer ResetTestStore(mr, store)
+	ctx := context.Background()
+	loadErr := errors.New("upstream down")
+
+	var v loadedValue
+	err := store.GetOrLoad(ctx, "cached", time.Minute, &v, func(ctx context.Context) (interface{}, error) {
+		return nil, loadErr
+	})
+	require.ErrorIs(t, err, loadErr)
+	// errors are not cached
+	require.False(t, mr.Exists("cached"))
+	require.False(t, mr.Exists(lockKey("cached")))
+}
+
+func TestGetOrLoadDeduplicates(t *testing.T) {
+	defer ResetTestStore(mr, store)
+	ctx := context.Background()
+	var calls int32
+
+	// a second process
+	other, err := NewClient(mr.Addr())
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		s := store
+		if i%2 == 1 {
+			s = other
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var v loadedValue
+			assert.NoError(t, s.GetOrLoad(ctx, "cached", time.Minute, &v, countingLoader(&calls, 100*time.Millisecond)))
+			assert.Equal(t, 1, v.Count)
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestGetOrLoadPanic(t *testing.T) {
+	defer ResetTestStore(mr, store)
+	ctx := context.Background()
+	var calls int32
+
+	var v loadedValue
+	require.Panics(t, func() {
+		_ = store.GetOrLoad(ctx, "cached", time.Minute, &v, func(context.Context) (interface{}, error) {
+			panic("loader failed")
+		})
+	})
+	require.False(t, mr.Exists(lockKey("cached")))
+
+	// the key is loaded again
+	done := make(chan error, 1)
+	go func() {
+		done <- store.GetOrLoad(ctx, "cached", time.Minute, &v, countingLoader(&calls, 0))
+	}()
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "GetOrLoad blocked after a panic")
+	}
+	require.Equal(t, 1, v.Count)
+}
+
+func TestGetOrLoadStale(t *testing.T) {
+	defer ResetTestStore(mr, store)
+	ctx := context.Background()
+	var calls int32
+
+	value, err := json.Marshal(loadedValue{Name: "stale"})
+	require.NoError(t, err)
+	entry, err := json.Marshal(cacheEntry{Value: value, FreshUntil: time.Now().Add(-time.Second)})
+	require.NoError(t, err)
+	require.NoError(t, store.Client.Set(ctx, "cached", entry, time.Minute).Err())
+
+	opts := LoadOptions{TTL: time.Minute, StaleTTL: time.Hour}
+
+	// the stale value is served while it's refreshed
+	var v loadedValue
+	require.NoError(t, store.GetOrLoadWithOptions(ctx, "cached", &v, countingLoader(&calls, 50*time.Millisecond), opts))
+	require.Equal(t, "stale", v.Name)
+
+	require.Eventually(t, func() bool {
+		var v loadedValue
+		require.NoError(t, store.GetOrLoadWithOptions(ctx, "cached", &v, countingLoader(&calls, 0), opts))
+		return v.Name == "loaded"
+	}, time.Second, 10*time.Millisecond)
+
+	require.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	require.Equal(t, time.Minute+time.Hour, mr.TTL("cached"))
+}
+
+func TestGetOrLoadStalePanic(t *testing.T) {
+	defer ResetTestStore(mr, store)
+	ctx := context.Background()
+
+	value, err := json.Marshal(loadedValue{Name: "stale"})
+	require.NoError(t, err)
+	entry, err := json.Marshal(cacheEntry{Value: value, FreshUntil: time.Now().Add(-time.Second)})
+	require.NoError(t, err)
+	require.NoError(t, store.Client.Set(ctx, "cached", entry, time.Minute).Err())
+
+	errs := make(chan error, 1)
+	opts := LoadOptions{
+		TTL:            time.Minute,
+		OnRefreshError: func(_ string, err error) { errs <- err },
+	}
+
+	// the refresh panics in the background, the stale value is kept
+	var v loadedValue
+	require.NoError(t, store.GetOrLoadWithOptions(ctx, "cached", &v, func(context.Context) (interface{}, error) {
+		panic("loader failed")
+	}, opts))
+	require.Equal(t, "stale", v.Name)
+
+	select {
+	case err := <-errs:
+		require.ErrorIs(t, err, errLoadPanicked)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "refresh panic not reported")
+	}
+
+	require.NoError(t, store.GetOrLoadWithOptions(ctx, "cached", &v, func(context.Context) (interface{}, error) {
+		return nil, errors.New("not refreshed")
+	}, opts))
+	require.Equal(t, "stale", v.Name)
+	require.Contains(t, (<-errs).Error(), "not refreshed")
+	require.False(t, mr.Exists(lockKey("cached")))
+}
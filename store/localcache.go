@@ -0,0 +1,273 @@
+package store
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	invalidationChannel = "cache/invalidate"
+
+	defaultLocalCacheSize = 128
+	defaultLocalCacheTTL  = 5 * time.Second
+)
+
+// LocalCacheOptions configures the in-process cache enabled by
+// EnableLocalCache.
+type LocalCacheOptions struct {
+	// Size is the maximum number of entries, defaults to 128. The least
+	// recently used entry is evicted when it's reached.
+	Size int
+	// TTL bounds the age of the entries, defaults to 5 seconds. Writes are
+	// broadcast so that entries are usually dropped much earlier, TTL is the
+	// bound holding when broadcasts are lost.
+	TTL time.Duration
+	// KeyTTLs overrides TTL for specific keys. A negative TTL disables the
+	// local cache for the key.
+	KeyTTLs map[string]time.Duration
+}
+
+// LocalCacheStats are the counters of the in-process cache.
+type LocalCacheStats struct {
+	Hits    uint64
+	Misses  uint64
+	Entries int
+}
+
+type localEntry struct {
+	key     string
+	value   []byte
+	meta    CacheMeta
+	expires time.Time
+}
+
+// localCache is a LRU cache of the values read through readCached.
+type localCache struct {
+	opts LocalCacheOptions
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+	closed  bool
+	hits    uint64
+	misses  uint64
+}
+
+func newLocalCache(opts LocalCacheOptions) *localCache {
+	if opts.Size <= 0 {
+		opts.Size = defaultLocalCacheSize
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = defaultLocalCacheTTL
+	}
+
+	return &localCache{
+		opts:    opts,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+func (c *localCache) ttl(key string) time.Duration {
+	if ttl, ok := c.opts.KeyTTLs[key]; ok {
+		return ttl
+	}
+
+	return c.opts.TTL
+}
+
+func (c *localCache) get(key string) (localEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok || c.closed {
+		c.misses++
+		return localEntry{}, false
+	}
+
+	e := el.Value.(localEntry)
+	if time.Now().After(e.expires) {
+		c.remove(el)
+		c.misses++
+		return localEntry{}, false
+	}
+
+	c.lru.MoveToFront(el)
+	c.hits++
+	return e, true
+}
+
+func (c *localCache) set(key string, value []byte, meta CacheMeta) {
+	ttl := c.ttl(key)
+	if ttl < 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return
+	}
+
+	e := localEntry{
+		key:     key,
+		value:   value,
+		meta:    meta,
+		expires: time.Now().Add(ttl),
+	}
+
+	if el, ok := c.entries[key]; ok {
+		el.Value = e
+		c.lru.MoveToFront(el)
+		return
+	}
+
+	c.entries[key] = c.lru.PushFront(e)
+	if c.lru.Len() > c.opts.Size {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *localCache) invalidate(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+}
+
+// close drops every entry and disables the cache.
+func (c *localCache) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+	c.entries = make(map[string]*list.Element)
+	c.lru.Init()
+}
+
+func (c *localCache) remove(el *list.Element) {
+	c.lru.Remove(el)
+	delete(c.entries, el.Value.(localEntry).key)
+}
+
+func (c *localCache) stats() LocalCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return LocalCacheStats{
+		Hits:    c.hits,
+		Misses:  c.misses,
+		Entries: c.lru.Len(),
+	}
+}
+
+// EnableLocalCache keeps the values read through GetPools, GetParams,
+// GetSupply, GetNodeInfo and their typed counterparts in memory.
+// Entries are dropped when a writer updates them through SetPools, SetParams,
+// SetSupply or SetNodeInfo, on any replica. The local cache is disabled once
+// ctx is done.
+// EnableLocalCache must be called before s is used concurrently.
+func (s *Store) EnableLocalCache(ctx context.Context, opts LocalCacheOptions) error {
+	pubsub := s.Client.Subscribe(ctx, invalidationChannel)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		_ = pubsub.Close()
+		return fmt.Errorf("cannot subscribe to cache invalidations, %w", err)
+	}
+
+	cache := newLocalCache(opts)
+	s.local = cache
+
+	go func() {
+		defer pubsub.Close()
+		defer cache.close()
+
+		msgs := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-msgs:
+				if !ok {
+					return
+				}
+
+				cache.invalidate(msg.Payload)
+			}
+		}
+	}()
+
+	return nil
+}
+
+// LocalCacheStats returns the counters of the local cache, which are zero
+// when it's not enabled.
+func (s *Store) LocalCacheStats() LocalCacheStats {
+	if s.local == nil {
+		return LocalCacheStats{}
+	}
+
+	return s.local.stats()
+}
+
+// readCached returns the value cached at key and its write metadata, from
+// the local cache when enabled.
+func (s *Store) readCached(ctx context.Context, key string) ([]byte, CacheMeta, error) {
+	if s.local != nil {
+		if e, ok := s.local.get(key); ok {
+			// callers may modify the value
+			return append([]byte(nil), e.value...), e.meta, nil
+		}
+	}
+
+	var (
+		value *redis.StringCmd
+		meta  *redis.StringStringMapCmd
+	)
+	_, err := s.Client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		value = pipe.Get(ctx, key)
+		meta = pipe.HGetAll(ctx, cacheMetaKey(key))
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, CacheMeta{}, err
+	}
+
+	bz, err := value.Bytes()
+	if err != nil {
+		return nil, CacheMeta{}, err
+	}
+
+	m, err := parseCacheMeta(meta.Val())
+	if err != nil {
+		return nil, CacheMeta{}, err
+	}
+
+	if s.local != nil {
+		s.local.set(key, append([]byte(nil), bz...), m)
+	}
+
+	return bz, m, nil
+}
+
+// invalidateCached drops key from the local cache of every replica.
+func (s *Store) invalidateCached(ctx context.Context, key string) error {
+	if s.local != nil {
+		s.local.invalidate(key)
+	}
+
+	if err := s.Client.Publish(ctx, invalidationChannel, key).Err(); err != nil {
+		return fmt.Errorf("cannot broadcast cache invalidation, %w", err)
+	}
+
+	return nil
+}
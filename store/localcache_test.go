@@ -0,0 +1,111 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/emerishq/emeris-utils/exported/sdktypes"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalCacheLRU(t *testing.T) {
+	c := newLocalCache(LocalCacheOptions{
+		Size:    2,
+		TTL:     time.Minute,
+		KeyTTLs: map[string]time.Duration{"uncached": -1},
+	})
+
+	c.set("a", []byte("a"), CacheMeta{})
+	c.set("b", []byte("b"), CacheMeta{})
+	_, ok := c.get("a")
+	require.True(t, ok)
+	// b is the least recently used
+	c.set("c", []byte("c"), CacheMeta{})
+	_, ok = c.get("b")
+	require.False(t, ok)
+	_, ok = c.get("a")
+	require.True(t, ok)
+
+	c.set("uncached", []byte("value"), CacheMeta{})
+	_, ok = c.get("uncached")
+	require.False(t, ok)
+
+	c.invalidate("a")
+	_, ok = c.get("a")
+	require.False(t, ok)
+
+	require.Equal(t, LocalCacheStats{Hits: 2, Misses: 3, Entries: 1}, c.stats())
+}
+
+func TestLocalCache(t *testing.T) {
+	defer ResetTestStore(mr, store)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// a replica reading through its local cache
+	replica, err := NewClient(mr.Addr())
+	require.NoError(t, err)
+	require.NoError(t, replica.EnableLocalCache(ctx, LocalCacheOptions{TTL: time.Minute}))
+
+	require.NoError(t, store.SetSupply(sdktypes.NewCoins(sdktypes.NewInt64Coin("stake", 1)), 1))
+
+	for i := 0; i < 10; i++ {
+		supply, err := replica.CachedSupply()
+		require.NoError(t, err)
+		require.Equal(t, "1stake", supply.Supply.String())
+		require.Equal(t, int64(1), supply.Height)
+	}
+	// raw reads share the entry
+	_, err = replica.GetSupply()
+	require.NoError(t, err)
+	require.Equal(t, LocalCacheStats{Hits: 10, Misses: 1, Entries: 1}, replica.LocalCacheStats())
+
+	// writes on any replica invalidate the entry
+	require.NoError(t, store.SetSupply(sdktypes.NewCoins(sdktypes.NewInt64Coin("stake", 2)), 2))
+	require.Eventually(t, func() bool {
+		supply, err := replica.CachedSupply()
+		require.NoError(t, err)
+		return supply.Supply.String() == "2stake"
+	}, time.Second, 10*time.Millisecond)
+
+	// the cache is disabled with its context
+	cancel()
+	require.Eventually(t, func() bool {
+		return replica.LocalCacheStats().Entries == 0
+	}, time.Second, 10*time.Millisecond)
+	_, err = replica.CachedSupply()
+	require.NoError(t, err)
+	require.Zero(t, replica.LocalCacheStats().Entries)
+}
+
+func TestLocalCacheTTL(t *testing.T) {
+	defer ResetTestStore(mr, store)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	replica, err := NewClient(mr.Addr())
+	require.NoError(t, err)
+	require.NoError(t, replica.EnableLocalCache(ctx, LocalCacheOptions{
+		TTL: time.Minute,
+		KeyTTLs: map[string]time.Duration{
+			paramsKey: 50 * time.Millisecond,
+		},
+	}))
+
+	require.NoError(t, store.SetParams(LiquidityParams{UnitBatchHeight: 1}, 1))
+	params, err := replica.CachedParams()
+	require.NoError(t, err)
+	require.Equal(t, uint32(1), params.Params.UnitBatchHeight)
+
+	// a write that is not broadcast is seen once the entry expires
+	require.NoError(t, store.Client.Set(ctx, paramsKey, `{"params":{"unit_batch_height":2}}`, 0).Err())
+	params, err = replica.CachedParams()
+	require.NoError(t, err)
+	require.Equal(t, uint32(1), params.Params.UnitBatchHeight)
+
+	time.Sleep(60 * time.Millisecond)
+	params, err = replica.CachedParams()
+	require.NoError(t, err)
+	require.Equal(t, uint32(2), params.Params.UnitBatchHeight)
+}
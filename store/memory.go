@@ -0,0 +1,487 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock returns the current time.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// ManualClock is a Clock that only moves when told to.
+type ManualClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewManualClock returns a ManualClock set to now.
+func NewManualClock(now time.Time) *ManualClock {
+	return &ManualClock{now: now}
+}
+
+// Now implements Clock.
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// Advance moves the clock forward by d.
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+type memTicket struct {
+	ticket Ticket
+	// expires is zero for tickets that don't expire
+	expires time.Time
+}
+
+type memHistory struct {
+	entries []TicketHistoryEntry
+	expires time.Time
+}
+
+// MemoryStore is an in-memory TicketStore, for tests. Expiries are computed
+// against its Clock.
+type MemoryStore struct {
+	Config struct {
+		ExpiryTime    time.Duration
+		Expiry        ExpiryPolicy
+		OwnerFallback OwnerFallback
+	}
+
+	clock Clock
+
+	mu      sync.Mutex
+	tickets map[string]memTicket
+	shadows map[string]time.Time
+	owners  map[string]map[string]struct{}
+	history map[string]memHistory
+	seq     int64
+}
+
+// NewMemoryStore returns an empty MemoryStore using clock, or the system
+// clock if nil.
+func NewMemoryStore(clock Clock) *MemoryStore {
+	if clock == nil {
+		clock = systemClock{}
+	}
+
+	m := &MemoryStore{
+		clock:   clock,
+		tickets: make(map[string]memTicket),
+		shadows: make(map[string]time.Time),
+		owners:  make(map[string]map[string]struct{}),
+		history: make(map[string]memHistory),
+	}
+	m.Config.ExpiryTime = defaultExpiry
+
+	return m
+}
+
+func (m *MemoryStore) expiresAt(d time.Duration) time.Time {
+	if d <= 0 {
+		return time.Time{}
+	}
+
+	return m.clock.Now().Add(d)
+}
+
+func (m *MemoryStore) expired(expires time.Time) bool {
+	return !expires.IsZero() && !m.clock.Now().Before(expires)
+}
+
+// get returns the ticket stored at key, m.mu must be held.
+func (m *MemoryStore) get(key string) (Ticket, bool) {
+	t, ok := m.tickets[key]
+	if !ok {
+		return Ticket{}, false
+	}
+
+	if m.expired(t.expires) {
+		delete(m.tickets, key)
+		return Ticket{}, false
+	}
+
+	return t.ticket, true
+}
+
+func (m *MemoryStore) appendHistory(key string, e TicketHistoryEntry, expiry time.Duration) {
+	m.seq++
+	e.ID = fmt.Sprintf("%d-%d", e.Timestamp.UnixNano()/int64(time.Millisecond), m.seq)
+
+	h := m.history[key]
+	if m.expired(h.expires) {
+		h = memHistory{}
+	}
+	h.entries = append(h.entries, e)
+	h.expires = m.expiresAt(expiry)
+	m.history[key] = h
+}
+
+// CreateTicketContext implements TicketStore.
+func (m *MemoryStore) CreateTicketContext(ctx context.Context, chain, txHash, owner string) error {
+	return m.CreateTicketWithOptionsContext(ctx, chain, txHash, owner, CreateOptions{})
+}
+
+// CreateTicketWithOptionsContext implements TicketStore.
+func (m *MemoryStore) CreateTicketWithOptionsContext(_ context.Context, chain, txHash, owner string, opts CreateOptions) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	owner, err := OwnerKey(owner, m.Config.OwnerFallback)
+	if err != nil {
+		return err
+	}
+	key := GetKey(chain, txHash)
+
+	if t, ok := m.get(key); ok && !opts.Force {
+		return &TicketExistsError{Key: key, Ticket: t}
+	}
+
+	expiry := m.Config.Expiry.status(StatusPending, m.Config.ExpiryTime)
+	now := m.clock.Now().UTC()
+	m.tickets[key] = memTicket{
+		ticket: Ticket{
+			Owner:     owner,
+			Status:    StatusPending,
+			CreatedAt: &now,
+		},
+		expires: m.expiresAt(expiry),
+	}
+	m.shadows[key] = m.expiresAt(m.Config.Expiry.shadow(m.Config.ExpiryTime))
+
+	if m.owners[owner] == nil {
+		m.owners[owner] = make(map[string]struct{})
+	}
+	m.owners[owner][key] = struct{}{}
+
+	delete(m.history, key)
+	m.appendHistory(key, TicketHistoryEntry{
+		Status:    StatusPending,
+		Chain:     chain,
+		TxHash:    txHash,
+		Timestamp: m.clock.Now(),
+	}, expiry)
+
+	return nil
+}
+
+// GetContext implements TicketStore.
+func (m *MemoryStore) GetContext(_ context.Context, key string) (Ticket, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, ok := m.get(key)
+	if !ok {
+		return Ticket{}, ErrTicketNotFound
+	}
+
+	return t, nil
+}
+
+// ownedKeys returns the sorted members of the owner set, m.mu must be held.
+func (m *MemoryStore) ownedKeys(owner string) []string {
+	keys := make([]string, 0, len(m.owners[owner]))
+	for k := range m.owners[owner] {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// GetUserTicketsContext implements TicketStore.
+func (m *MemoryStore) GetUserTicketsContext(_ context.Context, user string) (map[string][]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	owner, err := OwnerKey(user, m.Config.OwnerFallback)
+	if err != nil {
+		return map[string][]string{}, err
+	}
+
+	return groupTicketKeys(m.ownedKeys(owner))
+}
+
+// ListUserTicketsContext implements TicketStore. Tickets are returned sorted
+// by key.
+func (m *MemoryStore) ListUserTicketsContext(_ context.Context, owner string, filter ListFilter, cursor uint64) (TicketPage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+
+	ownerKey, err := OwnerKey(owner, m.Config.OwnerFallback)
+	if err != nil {
+		return TicketPage{}, err
+	}
+
+	keys := m.ownedKeys(ownerKey)
+	if cursor > uint64(len(keys)) {
+		cursor = uint64(len(keys))
+	}
+
+	end := cursor + uint64(limit)
+	next := end
+	if end >= uint64(len(keys)) {
+		end, next = uint64(len(keys)), 0
+	}
+
+	page := TicketPage{Cursor: next}
+	failed, pending := userTicketEntries(keys[cursor:end], filter)
+	page.Tickets = append(page.Tickets, failed...)
+
+	for _, t := range pending {
+		var err error
+		ticket, ok := m.get(t.Key)
+		if !ok {
+			err = ErrTicketNotFound
+		}
+
+		if t, ok := resolveUserTicket(t, ticket, err, filter); ok {
+			page.Tickets = append(page.Tickets, t)
+		}
+	}
+
+	return page, nil
+}
+
+// TicketHistoryContext implements TicketStore.
+func (m *MemoryStore) TicketHistoryContext(_ context.Context, key string) ([]TicketHistoryEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	h := m.history[key]
+	if m.expired(h.expires) {
+		delete(m.history, key)
+		return []TicketHistoryEntry{}, nil
+	}
+
+	return append([]TicketHistoryEntry{}, h.entries...), nil
+}
+
+// transition mirrors Store.transition.
+func (m *MemoryStore) transition(tr ticketTransition, extra func(t Ticket)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := tr.key
+
+	ticket, ok := m.get(key)
+	if !ok {
+		return ErrTicketNotFound
+	}
+
+	next, err := tr.nextStatus(ticket)
+	if err != nil {
+		return err
+	}
+
+	if tr.update != nil {
+		tr.update(&ticket)
+	}
+	ticket.Status = next
+
+	expiry := m.Config.Expiry.status(next, m.Config.ExpiryTime)
+	m.tickets[key] = memTicket{ticket: ticket, expires: m.expiresAt(expiry)}
+
+	if next.IsTerminal() {
+		delete(m.shadows, key)
+		delete(m.owners[ticket.Owner], key)
+	} else {
+		m.shadows[key] = m.expiresAt(m.Config.Expiry.shadow(m.Config.ExpiryTime))
+	}
+
+	entry := TicketHistoryEntry{
+		Status:    next,
+		Height:    ticket.Height,
+		Error:     ticket.Error,
+		Timestamp: m.clock.Now(),
+	}
+	if ticket.ErrorDetails != nil {
+		entry.ErrorCategory = ticket.ErrorDetails.Category
+	}
+	if tr.tx != nil {
+		entry.Chain = tr.tx.Chain
+		entry.TxHash = tr.tx.TxHash
+	}
+	m.appendHistory(key, entry, expiry)
+
+	if extra != nil {
+		extra(ticket)
+	}
+
+	return nil
+}
+
+func (m *MemoryStore) exists(key string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, ok := m.get(key)
+	return ok
+}
+
+// SetInTransitContext implements TicketStore.
+func (m *MemoryStore) SetInTransitContext(ctx context.Context, key, destChain, sourceChannel, sendPacketSequence, txHash, chainName string, height int64) error {
+	return m.SetInTransitRouteContext(ctx, key, []string{destChain}, sourceChannel, sendPacketSequence, txHash, chainName, height)
+}
+
+// SetInTransitRouteContext implements TicketStore.
+func (m *MemoryStore) SetInTransitRouteContext(_ context.Context, key string, route []string, sourceChannel, sendPacketSequence, txHash, chainName string, height int64) error {
+	if !m.exists(key) {
+		return fmt.Errorf("key doesn't exists")
+	}
+
+	hop := sendHop(firstChain(route), sourceChannel, sendPacketSequence, TxHashEntry{
+		Chain:  chainName,
+		Status: StatusTransit,
+		TxHash: txHash,
+	})
+
+	tr, err := inTransitTransition(key, route, hop, height)
+	if err != nil {
+		return err
+	}
+
+	return m.transition(tr, func(t Ticket) {
+		m.tickets[hop.PacketKey] = memTicket{
+			ticket:  packetTicket(key, t.Owner, hop.TxHashes[0]),
+			expires: m.expiresAt(m.Config.Expiry.packet(m.Config.ExpiryTime)),
+		}
+	})
+}
+
+// AddHopContext implements TicketStore.
+func (m *MemoryStore) AddHopContext(ctx context.Context, prevKey, destChain, sourceChannel, sendPacketSequence, txHash, chainName string, height int64) error {
+	prev, err := m.GetContext(ctx, prevKey)
+	if err != nil {
+		return err
+	}
+
+	hop := sendHop(destChain, sourceChannel, sendPacketSequence, TxHashEntry{
+		Chain:  chainName,
+		Status: StatusTransit,
+		TxHash: txHash,
+	})
+
+	return m.transition(addHopTransition(prevKey, prev, hop, height), func(t Ticket) {
+		m.tickets[hop.PacketKey] = memTicket{
+			ticket:  packetTicket(prev.Info, t.Owner, hop.TxHashes[0]),
+			expires: m.expiresAt(m.Config.Expiry.packet(m.Config.ExpiryTime)),
+		}
+	})
+}
+
+// setHopResult mirrors Store.setHopResult.
+func (m *MemoryStore) setHopResult(ctx context.Context, packetKey string, status TicketStatus, txHash, chainName string, height int64) error {
+	packet, err := m.GetContext(ctx, packetKey)
+	if err != nil {
+		return err
+	}
+
+	return m.transition(hopResultTransition(packetKey, packet, TxHashEntry{
+		Chain:  chainName,
+		Status: status,
+		TxHash: txHash,
+	}, height), nil)
+}
+
+// SetIbcReceivedContext implements TicketStore.
+func (m *MemoryStore) SetIbcReceivedContext(ctx context.Context, key, txHash, chainName string, height int64) error {
+	return m.setHopResult(ctx, key, StatusIBCReceiveSuccess, txHash, chainName, height)
+}
+
+// SetIbcFailedContext implements TicketStore.
+func (m *MemoryStore) SetIbcFailedContext(ctx context.Context, key, txHash, chainName string, height int64) error {
+	return m.setHopResult(ctx, key, StatusIBCReceiveFailed, txHash, chainName, height)
+}
+
+// SetIbcTimeoutUnlockContext implements TicketStore.
+func (m *MemoryStore) SetIbcTimeoutUnlockContext(ctx context.Context, key, txHash, chainName string, height int64) error {
+	return m.setHopResult(ctx, key, StatusTokensUnlockedTimeout, txHash, chainName, height)
+}
+
+// SetIbcAckUnlockContext implements TicketStore.
+func (m *MemoryStore) SetIbcAckUnlockContext(ctx context.Context, key, txHash, chainName string, height int64) error {
+	return m.setHopResult(ctx, key, StatusTokensUnlockedAck, txHash, chainName, height)
+}
+
+// SetCompleteContext implements TicketStore.
+func (m *MemoryStore) SetCompleteContext(_ context.Context, key string, height int64) error {
+	return m.transition(ticketTransition{
+		key:  key,
+		next: StatusComplete,
+		update: func(t *Ticket) {
+			t.Height = height
+		},
+	}, nil)
+}
+
+// SetFailedWithErrContext implements TicketStore.
+func (m *MemoryStore) SetFailedWithErrContext(ctx context.Context, key, error string, height int64) error {
+	return m.SetFailedWithTicketErrorContext(ctx, key, TicketError{
+		Category: ClassifyError("", 0, error),
+		Message:  error,
+	}, height)
+}
+
+// SetFailedWithTicketErrorContext implements TicketStore.
+func (m *MemoryStore) SetFailedWithTicketErrorContext(_ context.Context, key string, e TicketError, height int64) error {
+	if !m.exists(key) {
+		return fmt.Errorf("key doesn't exists")
+	}
+
+	return m.transition(failedTransition(key, e, height), nil)
+}
+
+// SetTimeoutContext implements TicketStore.
+func (m *MemoryStore) SetTimeoutContext(_ context.Context, key string) error {
+	return m.transition(ticketTransition{
+		key:  key,
+		next: StatusTimeout,
+	}, nil)
+}
+
+// ExpireShadowKeys calls handler with the key of every ticket whose shadow
+// key expired, as an ExpiryWatcher does on a Store.
+func (m *MemoryStore) ExpireShadowKeys(ctx context.Context, handler ExpiredHandler) error {
+	m.mu.Lock()
+	var keys []string
+	for key, expires := range m.shadows {
+		if m.expired(expires) {
+			keys = append(keys, key)
+			delete(m.shadows, key)
+		}
+	}
+	m.mu.Unlock()
+
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := handler(ctx, key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,60 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStoreExpiry(t *testing.T) {
+	ctx := context.Background()
+	clock := NewManualClock(time.Now())
+	m := NewMemoryStore(clock)
+
+	key := GetKey(testChain, testTxHash)
+	require.NoError(t, m.CreateTicketContext(ctx, testChain, testTxHash, testOwner))
+	require.NoError(t, m.SetInTransitContext(ctx, key, testDestChain, testSrcChannel, testPktSeq, testTxHash, testChain, 10))
+
+	// the shadow key expires first
+	clock.Advance(m.Config.ExpiryTime)
+	require.NoError(t, m.ExpireShadowKeys(ctx, TimeoutStuckTickets(m)))
+	ticket, err := m.GetContext(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, StatusTimeout, ticket.Status)
+
+	// then the terminal ticket, along with its history
+	clock.Advance(2 * m.Config.ExpiryTime)
+	_, err = m.GetContext(ctx, key)
+	require.ErrorIs(t, err, ErrTicketNotFound)
+	history, err := m.TicketHistoryContext(ctx, key)
+	require.NoError(t, err)
+	require.Empty(t, history)
+}
+
+func TestMemoryStoreShadowRefresh(t *testing.T) {
+	ctx := context.Background()
+	clock := NewManualClock(time.Now())
+	m := NewMemoryStore(clock)
+
+	var expired []string
+	handler := func(ctx context.Context, key string) error {
+		expired = append(expired, key)
+		return nil
+	}
+
+	key := GetKey(testChain, testTxHash)
+	require.NoError(t, m.CreateTicketContext(ctx, testChain, testTxHash, testOwner))
+	clock.Advance(m.Config.ExpiryTime / 2)
+	require.NoError(t, m.SetInTransitContext(ctx, key, testDestChain, testSrcChannel, testPktSeq, testTxHash, testChain, 10))
+
+	// transitions refresh the shadow key
+	clock.Advance(m.Config.ExpiryTime / 2)
+	require.NoError(t, m.ExpireShadowKeys(ctx, handler))
+	require.Empty(t, expired)
+
+	clock.Advance(m.Config.ExpiryTime / 2)
+	require.NoError(t, m.ExpireShadowKeys(ctx, handler))
+	require.Equal(t, []string{key}, expired)
+}
@@ -0,0 +1,288 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	metricsNamespace = "emeris"
+	metricsSubsystem = "store"
+
+	// openTicketsKey is a hash counting the non-terminal tickets by status.
+	// Every writer maintains it, whether it exports metrics or not.
+	openTicketsKey = "metrics/open_tickets"
+
+	openTicketsReadTimeout = 5 * time.Second
+)
+
+// metrics are the prometheus metrics of a Store, nil unless enabled.
+type metrics struct {
+	created     *prometheus.CounterVec
+	transitions *prometheus.CounterVec
+	duration    *prometheus.HistogramVec
+	redisErrors *prometheus.CounterVec
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		created: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "tickets_created_total",
+			Help:      "Number of tickets created, by chain.",
+		}, []string{"chain"}),
+		transitions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "ticket_transitions_total",
+			Help:      "Number of ticket status changes, by previous and new status.",
+		}, []string{"from", "to"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "ticket_duration_seconds",
+			Help:      "Time from the creation of a ticket to its terminal status, by terminal status.",
+			Buckets:   prometheus.ExponentialBuckets(1, 2, 12),
+		}, []string{"status"}),
+		redisErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "redis_errors_total",
+			Help:      "Number of failed redis commands, by command.",
+		}, []string{"command"}),
+	}
+}
+
+// EnableMetrics registers the metrics of s on reg:
+//   - emeris_store_tickets_created_total, by chain,
+//   - emeris_store_ticket_transitions_total, by previous and new status,
+//   - emeris_store_ticket_duration_seconds, the time from creation to a
+//     terminal status,
+//   - emeris_store_open_tickets, the current number of tickets in each
+//     non-terminal status, shared by every replica,
+//   - emeris_store_redis_errors_total, by command.
+//
+// Open tickets written by earlier versions of the store, or expiring without
+// reaching a terminal status, are only accounted for by RecountOpenTickets,
+// which must run periodically, see RecountOpenTicketsEvery.
+// EnableMetrics must be called before s is used concurrently.
+func (s *Store) EnableMetrics(reg prometheus.Registerer) error {
+	m := newMetrics()
+
+	collectors := []prometheus.Collector{
+		m.created,
+		m.transitions,
+		m.duration,
+		m.redisErrors,
+		openTicketsCollector{store: s, desc: openTicketsDesc},
+	}
+	for _, c := range collectors {
+		if err := reg.Register(c); err != nil {
+			return err
+		}
+	}
+
+	s.metrics = m
+	s.Client.AddHook(metricsHook{m})
+
+	return nil
+}
+
+func (s *Store) observeCreated(chain string) {
+	if s.metrics == nil {
+		return
+	}
+
+	s.metrics.created.WithLabelValues(chain).Inc()
+}
+
+func (s *Store) observeTransition(prev TicketStatus, t Ticket) {
+	if s.metrics == nil {
+		return
+	}
+
+	s.metrics.transitions.WithLabelValues(string(prev), string(t.Status)).Inc()
+
+	if t.Status.IsTerminal() && t.CreatedAt != nil && !t.CreatedAt.IsZero() {
+		s.metrics.duration.WithLabelValues(string(t.Status)).Observe(time.Since(*t.CreatedAt).Seconds())
+	}
+}
+
+// countOpenTickets queues the update of the open tickets counts for a ticket
+// moving from prev to next, prev is empty for created tickets.
+func countOpenTickets(ctx context.Context, pipe redis.Pipeliner, prev, next TicketStatus) {
+	if prev == next {
+		return
+	}
+
+	if prev != "" && !prev.IsTerminal() {
+		pipe.HIncrBy(ctx, openTicketsKey, string(prev), -1)
+	}
+
+	if !next.IsTerminal() {
+		pipe.HIncrBy(ctx, openTicketsKey, string(next), 1)
+	}
+}
+
+// RecountOpenTickets replaces the open tickets counts with the number of
+// tickets found in each non-terminal status through the owner sets, which
+// hold every non-terminal ticket.
+// Counts are otherwise only updated when tickets are written, so tickets
+// expiring before reaching a terminal status stay counted until the next
+// recount. Tickets written during a recount may be miscounted until the next
+// one.
+func (s *Store) RecountOpenTickets() error {
+	return s.RecountOpenTicketsContext(context.Background())
+}
+
+// RecountOpenTicketsContext is like RecountOpenTickets, with a context.
+func (s *Store) RecountOpenTicketsContext(ctx context.Context) error {
+	owners, err := s.ownerSets(ctx)
+	if err != nil {
+		return err
+	}
+
+	counts := make(map[TicketStatus]int64)
+	for status := range transitions {
+		counts[status] = 0
+	}
+
+	for _, owner := range owners {
+		keys, err := s.sMembers(ctx, owner)
+		if err != nil {
+			return fmt.Errorf("cannot read owner set %s, %w", owner, err)
+		}
+
+		values, _, err := getMany(ctx, s.Client, keys)
+		if err != nil {
+			return fmt.Errorf("cannot read tickets of %s, %w", owner, err)
+		}
+
+		for _, v := range values {
+			var t Ticket
+			// malformed and expired tickets are not open
+			if err := t.UnmarshalBinary([]byte(v)); err != nil || t.Status.IsTerminal() {
+				continue
+			}
+
+			counts[t.Status]++
+		}
+	}
+
+	values := make(map[string]interface{}, len(counts))
+	for status, n := range counts {
+		values[string(status)] = n
+	}
+
+	_, err = s.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, openTicketsKey)
+		pipe.HSet(ctx, openTicketsKey, values)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("cannot write open tickets counts, %w", err)
+	}
+
+	return nil
+}
+
+// RecountOpenTicketsEvery runs RecountOpenTickets every interval in the
+// background, until ctx is done. Errors are passed to onError, if not nil.
+func (s *Store) RecountOpenTicketsEvery(ctx context.Context, interval time.Duration, onError func(err error)) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			if err := s.RecountOpenTicketsContext(ctx); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}()
+}
+
+var openTicketsDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(metricsNamespace, metricsSubsystem, "open_tickets"),
+	"Number of tickets in a non-terminal status.",
+	[]string{"status"}, nil,
+)
+
+// openTicketsCollector reads the open tickets counts when collected.
+type openTicketsCollector struct {
+	store *Store
+	desc  *prometheus.Desc
+}
+
+func (c openTicketsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c openTicketsCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), openTicketsReadTimeout)
+	defer cancel()
+
+	counts, err := c.store.Client.HGetAll(ctx, openTicketsKey).Result()
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.desc, err)
+		return
+	}
+
+	for status, count := range counts {
+		n, err := strconv.ParseFloat(count, 64)
+		if err != nil {
+			ch <- prometheus.NewInvalidMetric(c.desc, err)
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, n, status)
+	}
+}
+
+// metricsHook counts the failed redis commands. Missing keys and aborted
+// transactions are not failures.
+type metricsHook struct {
+	m *metrics
+}
+
+func (h metricsHook) observe(cmd redis.Cmder) {
+	err := cmd.Err()
+	if err == nil || errors.Is(err, redis.Nil) || errors.Is(err, redis.TxFailedErr) {
+		return
+	}
+
+	h.m.redisErrors.WithLabelValues(cmd.Name()).Inc()
+}
+
+func (metricsHook) BeforeProcess(ctx context.Context, _ redis.Cmder) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h metricsHook) AfterProcess(_ context.Context, cmd redis.Cmder) error {
+	h.observe(cmd)
+	return nil
+}
+
+func (metricsHook) BeforeProcessPipeline(ctx context.Context, _ []redis.Cmder) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h metricsHook) AfterProcessPipeline(_ context.Context, cmds []redis.Cmder) error {
+	for _, cmd := range cmds {
+		h.observe(cmd)
+	}
+
+	return nil
+}
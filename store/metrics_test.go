@@ -0,0 +1,80 @@
+package store
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	m, s := SetupTestStore()
+	defer m.Close()
+
+	reg := prometheus.NewRegistry()
+	require.NoError(t, s.EnableMetrics(reg))
+	// metrics can only be registered once
+	require.Error(t, s.EnableMetrics(reg))
+
+	key := GetKey(testChain, testTxHash)
+	require.NoError(t, s.CreateTicket(testChain, testTxHash, testOwner))
+	require.NoError(t, s.CreateTicket(testChain, "OTHER", testOwner))
+	require.NoError(t, s.SetInTransit(key, testDestChain, testSrcChannel, testPktSeq, testTxHash, testChain, 10))
+	require.NoError(t, s.SetIbcReceived(GetIBCKey(testDestChain, testSrcChannel, testPktSeq), "RECV", testDestChain, 11))
+	// created over a pending ticket
+	require.NoError(t, s.CreateTicketWithOptions(testChain, "OTHER", testOwner, CreateOptions{Force: true}))
+
+	require.Equal(t, 3.0, testutil.ToFloat64(s.metrics.created.WithLabelValues(testChain)))
+	require.Equal(t, 1.0, testutil.ToFloat64(s.metrics.transitions.WithLabelValues("pending", "transit")))
+	require.Equal(t, 1.0, testutil.ToFloat64(s.metrics.transitions.WithLabelValues("transit", "IBC_receive_success")))
+	require.Equal(t, 1, testutil.CollectAndCount(s.metrics.duration))
+
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	open := map[string]float64{}
+	for _, f := range families {
+		if f.GetName() != "emeris_store_open_tickets" {
+			continue
+		}
+
+		for _, metric := range f.GetMetric() {
+			open[metric.GetLabel()[0].GetValue()] = metric.GetGauge().GetValue()
+		}
+	}
+	require.Equal(t, map[string]float64{"pending": 1, "transit": 0}, open)
+
+	m.SetError("server down")
+	_, err = s.Get(key)
+	require.Error(t, err)
+	require.Equal(t, 1.0, testutil.ToFloat64(s.metrics.redisErrors.WithLabelValues("get")))
+
+	m.SetError("")
+	_, err = s.Get("missing")
+	require.True(t, errors.Is(err, ErrTicketNotFound))
+	require.Equal(t, 1.0, testutil.ToFloat64(s.metrics.redisErrors.WithLabelValues("get")))
+}
+
+func TestRecountOpenTickets(t *testing.T) {
+	defer ResetTestStore(mr, store)
+
+	key := GetKey(testChain, testTxHash)
+	require.NoError(t, store.CreateTicket(testChain, testTxHash, testOwner))
+	require.NoError(t, store.SetInTransit(key, testDestChain, testSrcChannel, testPktSeq, testTxHash, testChain, 10))
+	require.NoError(t, store.CreateTicket(testChain, "PENDING", testOwner))
+	require.NoError(t, store.CreateTicket(testChain, "COMPLETE", testOsmoOwner))
+	require.NoError(t, store.SetComplete(GetKey(testChain, "COMPLETE"), 10))
+	require.NoError(t, store.CreateTicket(testChain, "EXPIRED", "owner"))
+
+	// the ticket expires in transit, without a transition
+	require.NoError(t, store.SetInTransit(GetKey(testChain, "EXPIRED"), testDestChain, testSrcChannel, "2", "EXPIRED", testChain, 10))
+	mr.Del(GetKey(testChain, "EXPIRED"))
+	require.Equal(t, "2", mr.HGet(openTicketsKey, string(StatusTransit)))
+
+	require.NoError(t, store.RecountOpenTickets())
+	require.Equal(t, "1", mr.HGet(openTicketsKey, string(StatusPending)))
+	require.Equal(t, "1", mr.HGet(openTicketsKey, string(StatusTransit)))
+	require.Equal(t, "0", mr.HGet(openTicketsKey, string(StatusIBCReceiveFailed)))
+	require.Empty(t, mr.HGet(openTicketsKey, string(StatusComplete)))
+}
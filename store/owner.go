@@ -0,0 +1,207 @@
+package store
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+
+	"github.com/cosmos/cosmos-sdk/types/bech32"
+	"github.com/go-redis/redis/v8"
+)
+
+// ownerScanCount is the number of keys read per SCAN call by ReindexOwners.
+const ownerScanCount = 1000
+
+// ErrInvalidOwner is returned for the owners rejected by an OwnerFallback.
+var ErrInvalidOwner = errors.New("invalid owner")
+
+// ownerKeyRegexp matches the keys of the owner sets.
+var ownerKeyRegexp = regexp.MustCompile(`^([0-9a-f]{2})+$`)
+
+// Tickets are indexed by the hex encoded bytes of the address of their owner,
+// so that the tickets of an account are grouped whatever the bech32 prefix
+// used to create them, e.g. cosmos1... and osmo1....
+
+// OwnerFallback returns the bytes identifying the owners that are not bech32
+// addresses.
+type OwnerFallback func(owner string) ([]byte, error)
+
+// RawOwner identifies owners by their string, as all owners were before
+// being normalized.
+func RawOwner(owner string) ([]byte, error) {
+	return []byte(owner), nil
+}
+
+// RejectOwner rejects the owners that are not bech32 addresses.
+func RejectOwner(owner string) ([]byte, error) {
+	return nil, fmt.Errorf("%w %s, not a bech32 address", ErrInvalidOwner, owner)
+}
+
+// OwnerKey returns the key indexing the tickets of owner: the hex encoded
+// bytes of its address for bech32 addresses, of fallback(owner) otherwise.
+// RawOwner is used when fallback is nil.
+func OwnerKey(owner string, fallback OwnerFallback) (string, error) {
+	_, bz, err := bech32.DecodeAndConvert(owner)
+	if err != nil {
+		if fallback == nil {
+			fallback = RawOwner
+		}
+
+		if bz, err = fallback(owner); err != nil {
+			return "", err
+		}
+	}
+
+	return hex.EncodeToString(bz), nil
+}
+
+func (s *Store) ownerKey(owner string) (string, error) {
+	return OwnerKey(owner, s.Config.OwnerFallback)
+}
+
+// ReindexOwners moves the owner sets indexed by the raw owner string, as
+// created before owners were normalized, to their normalized key. The owner
+// of their tickets, and of the IBC packets of their hops, is updated as well.
+// It returns the number of owner sets moved.
+// Owner sets already normalized, or whose owner is rejected by the
+// OwnerFallback, are left untouched, so ReindexOwners can run again if
+// interrupted, or while replicas running a previous version still write raw
+// owner sets. Retained ticket events are not moved.
+func (s *Store) ReindexOwners() (int, error) {
+	return s.ReindexOwnersContext(context.Background())
+}
+
+// ReindexOwnersContext is like ReindexOwners, with a context.
+func (s *Store) ReindexOwnersContext(ctx context.Context) (int, error) {
+	keys, err := s.ownerSets(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	moved := 0
+	for _, key := range keys {
+		ok, err := s.reindexOwner(ctx, key)
+		if err != nil {
+			return moved, err
+		}
+
+		if ok {
+			moved++
+		}
+	}
+
+	return moved, nil
+}
+
+// ownerSets returns the keys of the owner sets, on every node of a cluster.
+func (s *Store) ownerSets(ctx context.Context) ([]string, error) {
+	var (
+		mu   sync.Mutex
+		keys []string
+	)
+	err := s.forEachNode(ctx, func(ctx context.Context, client redis.UniversalClient) error {
+		iter := client.ScanType(ctx, 0, "", ownerScanCount, "set").Iterator()
+		for iter.Next(ctx) {
+			if ownerKeyRegexp.MatchString(iter.Val()) {
+				mu.Lock()
+				keys = append(keys, iter.Val())
+				mu.Unlock()
+			}
+		}
+
+		return iter.Err()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot scan owner sets, %w", err)
+	}
+
+	return keys, nil
+}
+
+// reindexOwner moves the owner set stored at key to its normalized key, and
+// returns true if it was moved.
+func (s *Store) reindexOwner(ctx context.Context, key string) (bool, error) {
+	raw, err := hex.DecodeString(key)
+	if err != nil {
+		return false, nil
+	}
+
+	newKey, err := s.ownerKey(string(raw))
+	if err != nil || newKey == key {
+		return false, nil
+	}
+
+	members, err := s.sMembers(ctx, key)
+	if err != nil {
+		return false, fmt.Errorf("cannot read owner set %s, %w", key, err)
+	}
+
+	if len(members) == 0 {
+		return false, nil
+	}
+
+	ms := make([]interface{}, 0, len(members))
+	for _, member := range members {
+		ms = append(ms, member)
+
+		ticket, err := s.setOwner(ctx, member, key, newKey)
+		if err != nil {
+			return false, err
+		}
+
+		for _, hop := range ticket.Hops {
+			if _, err := s.setOwner(ctx, hop.PacketKey, key, newKey); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	// members added to key meanwhile are moved by the next run, the ones
+	// that reached a terminal status meanwhile are moved anyway and listed
+	// like expired tickets
+	_, err = s.Client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.SAdd(ctx, newKey, ms...)
+		pipe.SRem(ctx, key, ms...)
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("cannot move owner set %s, %w", key, err)
+	}
+
+	return true, nil
+}
+
+// setOwner replaces the owner of the ticket stored at key, keeping its
+// expiry, if it is owned by from. Missing tickets are ignored.
+func (s *Store) setOwner(ctx context.Context, key, from, to string) (Ticket, error) {
+	var ticket Ticket
+	err := s.Client.Watch(ctx, func(tx *redis.Tx) error {
+		ticket = Ticket{}
+		err := tx.Get(ctx, key).Scan(&ticket)
+		if errors.Is(err, redis.Nil) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if ticket.Owner != from {
+			return nil
+		}
+
+		ticket.Owner = to
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.SetArgs(ctx, key, ticket, redis.SetArgs{KeepTTL: true})
+			return nil
+		})
+		return err
+	}, key)
+	if err != nil {
+		return Ticket{}, fmt.Errorf("cannot update owner of %s, %w", key, err)
+	}
+
+	return ticket, nil
+}
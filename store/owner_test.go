@@ -0,0 +1,93 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testOsmoOwner = "osmo1l2lepugxx5heetsl2cs74e2sy0uqxv398xrw5u"
+
+func TestOwnerKey(t *testing.T) {
+	key, err := OwnerKey(testOwner, nil)
+	require.NoError(t, err)
+	require.Equal(t, "fabf90f106352f9cae1f5621eae55023f8033225", key)
+
+	for _, owner := range []string{testOsmoOwner, "OSMO1L2LEPUGXX5HEETSL2CS74E2SY0UQXV398XRW5U"} {
+		other, err := OwnerKey(owner, RejectOwner)
+		require.NoError(t, err)
+		require.Equal(t, key, other)
+	}
+
+	// a bad checksum makes the owner a raw string
+	key, err = OwnerKey("cosmos1l2lepugxx5heetsl2cs74e2sy0uqxv390as7zx", nil)
+	require.NoError(t, err)
+	require.Equal(t, fmt.Sprintf("%x", "cosmos1l2lepugxx5heetsl2cs74e2sy0uqxv390as7zx"), key)
+
+	_, err = OwnerKey("owner", RejectOwner)
+	require.ErrorIs(t, err, ErrInvalidOwner)
+}
+
+func TestOwnerFallback(t *testing.T) {
+	defer ResetTestStore(mr, store)
+	defer func() { store.Config.OwnerFallback = nil }()
+
+	store.Config.OwnerFallback = RejectOwner
+	require.ErrorIs(t, store.CreateTicket(testChain, testTxHash, "owner"), ErrInvalidOwner)
+	_, err := store.GetUserTickets("owner")
+	require.ErrorIs(t, err, ErrInvalidOwner)
+	_, err = store.ListUserTickets("owner", ListFilter{}, 0)
+	require.ErrorIs(t, err, ErrInvalidOwner)
+
+	require.NoError(t, store.CreateTicket(testChain, testTxHash, testOwner))
+}
+
+func TestReindexOwners(t *testing.T) {
+	defer ResetTestStore(mr, store)
+	ctx := context.Background()
+
+	// tickets indexed by the raw owner, with an IBC packet
+	rawKey := fmt.Sprintf("%x", testOsmoOwner)
+	key := GetKey(testChain, testTxHash)
+	packetKey := GetIBCKey(testDestChain, testSrcChannel, testPktSeq)
+	require.NoError(t, store.Client.Set(ctx, key, Ticket{
+		Owner:  rawKey,
+		Status: StatusTransit,
+		Hops:   []Hop{{PacketKey: packetKey}},
+	}, time.Minute).Err())
+	require.NoError(t, store.Client.Set(ctx, packetKey, packetTicket(key, rawKey, TxHashEntry{}), time.Minute).Err())
+	require.NoError(t, store.Client.SAdd(ctx, rawKey, key, GetKey(testChain, "EXPIRED")).Err())
+
+	// tickets indexed by a raw owner that is not an address
+	otherKey := fmt.Sprintf("%x", "owner")
+	require.NoError(t, store.Client.SAdd(ctx, otherKey, GetKey(testChain, "OTHER")).Err())
+
+	require.NoError(t, store.CreateTicket(testDestChain, "NEW", testOwner))
+
+	moved, err := store.ReindexOwners()
+	require.NoError(t, err)
+	require.Equal(t, 1, moved)
+
+	tickets, err := store.GetUserTickets(testOwner)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{testTxHash, "EXPIRED"}, tickets[testChain])
+	require.Equal(t, []string{"NEW"}, tickets[testDestChain])
+	require.False(t, mr.Exists(rawKey))
+	require.True(t, mr.Exists(otherKey))
+
+	ownerKey, err := OwnerKey(testOwner, nil)
+	require.NoError(t, err)
+	for _, k := range []string{key, packetKey} {
+		ticket, err := store.Get(k)
+		require.NoError(t, err)
+		require.Equal(t, ownerKey, ticket.Owner)
+		require.Equal(t, time.Minute, mr.TTL(k))
+	}
+
+	moved, err = store.ReindexOwners()
+	require.NoError(t, err)
+	require.Zero(t, moved)
+}
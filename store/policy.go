@@ -0,0 +1,179 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// multipliers of the base expiry used by the default policy
+	ticketExpiryMul = 2
+	packetExpiryMul = 2
+	eventsExpiryMul = 2
+	poolExpiryMul   = 12
+)
+
+// ExpiryPolicy sets how long the keys of a Store live. Fields left unset fall
+// back to multiples of the base expiry, Config.ExpiryTime:
+//   - tickets in transit or in a terminal status live twice the base expiry,
+//   - pending tickets, and tickets whose IBC receive failed, don't expire,
+//   - shadow keys live the base expiry,
+//   - packet tickets live twice the base expiry,
+//   - the ticket events of an owner are kept twice the base expiry after
+//     the last one,
+//   - pool swap fees are kept 12 times the base expiry.
+//
+// ExpiryPolicy implements configuration.Validator.
+type ExpiryPolicy struct {
+	// Statuses maps a ticket status to how long tickets in that status are
+	// kept after their last transition, 0 meaning forever. Statuses are
+	// matched regardless of case, since configuration keys are lowercased.
+	Statuses map[TicketStatus]time.Duration
+	// Shadow is how long a non-terminal ticket can go without a transition
+	// before the ExpiryWatcher is notified.
+	Shadow time.Duration
+	// Packet is how long the tickets stored at the packet keys of IBC hops
+	// are kept.
+	Packet time.Duration
+	// Events is how long the ticket events of an owner are kept after the
+	// last one, for subscribers to resume from.
+	Events time.Duration
+	// SwapFees is how long pool swap fees are kept. Set it to the widest
+	// window queried through SwapFeesBucketed.
+	SwapFees time.Duration
+}
+
+// DefaultExpiryPolicy returns the policy used with the base expiry, with
+// every field set.
+func DefaultExpiryPolicy(base time.Duration) ExpiryPolicy {
+	return ExpiryPolicy{}.withDefaults(base)
+}
+
+// withDefaults returns p with the unset fields computed from base.
+func (p ExpiryPolicy) withDefaults(base time.Duration) ExpiryPolicy {
+	statuses := make(map[TicketStatus]time.Duration, len(allStatuses))
+	for _, st := range allStatuses {
+		statuses[st] = p.status(st, base)
+	}
+
+	return ExpiryPolicy{
+		Statuses: statuses,
+		Shadow:   p.shadow(base),
+		Packet:   p.packet(base),
+		Events:   p.events(base),
+		SwapFees: p.swapFees(base),
+	}
+}
+
+// Validate implements configuration.Validator.
+func (p ExpiryPolicy) Validate() error {
+	for st, d := range p.Statuses {
+		known, ok := knownStatus(st)
+		if !ok {
+			return fmt.Errorf("unknown ticket status %s in expiry policy", st)
+		}
+
+		if d < 0 {
+			return fmt.Errorf("invalid %s tickets expiry %s", known, d)
+		}
+
+		// the ticket must outlive its shadow key, so that it can be handled
+		// when the ExpiryWatcher is notified
+		if d != 0 && !known.IsTerminal() && p.Shadow > 0 && d < p.Shadow {
+			return fmt.Errorf("%s tickets expiry %s is shorter than shadow keys expiry %s", known, d, p.Shadow)
+		}
+	}
+
+	if p.Shadow < 0 {
+		return fmt.Errorf("invalid shadow keys expiry %s", p.Shadow)
+	}
+
+	if p.Packet < 0 {
+		return fmt.Errorf("invalid packet tickets expiry %s", p.Packet)
+	}
+
+	if p.Events < 0 {
+		return fmt.Errorf("invalid ticket events expiry %s", p.Events)
+	}
+
+	if p.SwapFees < 0 {
+		return fmt.Errorf("invalid swap fees expiry %s", p.SwapFees)
+	}
+
+	return nil
+}
+
+// status returns how long tickets in status st are kept, 0 meaning forever.
+func (p ExpiryPolicy) status(st TicketStatus, base time.Duration) time.Duration {
+	if d, ok := p.Statuses[st]; ok {
+		return d
+	}
+
+	for s, d := range p.Statuses {
+		if strings.EqualFold(string(s), string(st)) {
+			return d
+		}
+	}
+
+	switch st {
+	case StatusPending, StatusIBCReceiveFailed:
+		// a failed receive is followed by the unlock of the tokens, the
+		// ticket doesn't expire meanwhile
+		return 0
+	default:
+		return ticketExpiryMul * base
+	}
+}
+
+func (p ExpiryPolicy) shadow(base time.Duration) time.Duration {
+	if p.Shadow > 0 {
+		return p.Shadow
+	}
+
+	return base
+}
+
+func (p ExpiryPolicy) packet(base time.Duration) time.Duration {
+	if p.Packet > 0 {
+		return p.Packet
+	}
+
+	return packetExpiryMul * base
+}
+
+func (p ExpiryPolicy) events(base time.Duration) time.Duration {
+	if p.Events > 0 {
+		return p.Events
+	}
+
+	return eventsExpiryMul * base
+}
+
+func (p ExpiryPolicy) swapFees(base time.Duration) time.Duration {
+	if p.SwapFees > 0 {
+		return p.SwapFees
+	}
+
+	return poolExpiryMul * base
+}
+
+func (s *Store) ticketExpiry(st TicketStatus) time.Duration {
+	return s.Config.Expiry.status(st, s.Config.ExpiryTime)
+}
+
+func (s *Store) shadowExpiry() time.Duration {
+	return s.Config.Expiry.shadow(s.Config.ExpiryTime)
+}
+
+func (s *Store) packetExpiry() time.Duration {
+	return s.Config.Expiry.packet(s.Config.ExpiryTime)
+}
+
+func (s *Store) eventsExpiry() time.Duration {
+	return s.Config.Expiry.events(s.Config.ExpiryTime)
+}
+
+func (s *Store) swapFeesExpiry() time.Duration {
+	return s.Config.Expiry.swapFees(s.Config.ExpiryTime)
+}
@@ -0,0 +1,70 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultExpiryPolicy(t *testing.T) {
+	p := DefaultExpiryPolicy(time.Minute)
+	require.NoError(t, p.Validate())
+	require.Zero(t, p.Statuses[StatusPending])
+	require.Zero(t, p.Statuses[StatusIBCReceiveFailed])
+	require.Equal(t, 2*time.Minute, p.Statuses[StatusTransit])
+	require.Equal(t, 2*time.Minute, p.Statuses[StatusComplete])
+	require.Equal(t, time.Minute, p.Shadow)
+	require.Equal(t, 2*time.Minute, p.Packet)
+	require.Equal(t, 2*time.Minute, p.Events)
+	require.Equal(t, 12*time.Minute, p.SwapFees)
+}
+
+func TestExpiryPolicyValidate(t *testing.T) {
+	require.NoError(t, ExpiryPolicy{}.Validate())
+	require.NoError(t, ExpiryPolicy{Statuses: map[TicketStatus]time.Duration{
+		// configuration keys are lowercased
+		"ibc_receive_failed": time.Hour,
+		StatusComplete:       time.Second,
+	}}.Validate())
+
+	require.Error(t, ExpiryPolicy{Statuses: map[TicketStatus]time.Duration{"unknown": time.Hour}}.Validate())
+	require.Error(t, ExpiryPolicy{Statuses: map[TicketStatus]time.Duration{StatusFailed: -time.Hour}}.Validate())
+	require.Error(t, ExpiryPolicy{Shadow: -time.Second}.Validate())
+	require.Error(t, ExpiryPolicy{Packet: -time.Second}.Validate())
+
+	// non-terminal tickets can't expire before their shadow key
+	p := ExpiryPolicy{Statuses: map[TicketStatus]time.Duration{StatusTransit: time.Minute}}
+	require.NoError(t, p.Validate())
+	require.Error(t, Options{Addr: "localhost:6379", ExpiryTime: 5 * time.Minute, Expiry: p}.Validate())
+	require.NoError(t, Options{Addr: "localhost:6379", ExpiryTime: 30 * time.Second, Expiry: p}.Validate())
+}
+
+func TestExpiryPolicyTickets(t *testing.T) {
+	defer ResetTestStore(mr, store)
+	defer func() { store.Config.Expiry = ExpiryPolicy{} }()
+	store.Config.Expiry = ExpiryPolicy{
+		Statuses: map[TicketStatus]time.Duration{
+			StatusPending:  time.Hour,
+			StatusFailed:   72 * time.Hour,
+			StatusComplete: time.Minute,
+		},
+		Shadow: 10 * time.Minute,
+	}
+
+	key := GetKey(testChain, testTxHash)
+	require.NoError(t, store.CreateTicket(testChain, testTxHash, testOwner))
+	require.Equal(t, time.Hour, mr.TTL(key))
+	require.Equal(t, 10*time.Minute, mr.TTL(getShadowKey(key)))
+
+	require.NoError(t, store.SetFailedWithErr(key, testErr, 12))
+	require.Equal(t, 72*time.Hour, mr.TTL(key))
+	require.Equal(t, 72*time.Hour, mr.TTL(historyKey(key)))
+
+	require.NoError(t, store.CreateTicketWithOptions(testChain, testTxHash, testOwner, CreateOptions{Force: true}))
+	require.NoError(t, store.SetComplete(key, 12))
+	require.Equal(t, time.Minute, mr.TTL(key))
+
+	require.NoError(t, store.CreateShadowKey(key))
+	require.Equal(t, 10*time.Minute, mr.TTL(getShadowKey(key)))
+}
@@ -0,0 +1,118 @@
+package store
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrInvalidTransition is returned when a ticket is asked to move to a status
+// that is not reachable from its current one.
+var ErrInvalidTransition = errors.New("invalid ticket status transition")
+
+// TicketStatus is the lifecycle state of a ticket.
+type TicketStatus string
+
+const (
+	StatusPending               TicketStatus = "pending"
+	StatusTransit               TicketStatus = "transit"
+	StatusComplete              TicketStatus = "complete"
+	StatusFailed                TicketStatus = "failed"
+	StatusIBCReceiveFailed      TicketStatus = "IBC_receive_failed"
+	StatusIBCReceiveSuccess     TicketStatus = "IBC_receive_success"
+	StatusTokensUnlockedTimeout TicketStatus = "Tokens_unlocked_timeout"
+	StatusTokensUnlockedAck     TicketStatus = "Tokens_unlocked_ack"
+	StatusTimeout               TicketStatus = "timeout"
+)
+
+var allStatuses = []TicketStatus{
+	StatusPending,
+	StatusTransit,
+	StatusComplete,
+	StatusFailed,
+	StatusIBCReceiveFailed,
+	StatusIBCReceiveSuccess,
+	StatusTokensUnlockedTimeout,
+	StatusTokensUnlockedAck,
+	StatusTimeout,
+}
+
+// transitions declares, for every non-terminal status, the statuses a ticket
+// can move to. Statuses without an entry are terminal.
+var transitions = map[TicketStatus][]TicketStatus{
+	StatusPending: {
+		StatusTransit,
+		StatusComplete,
+		StatusFailed,
+		StatusTimeout,
+	},
+	StatusTransit: {
+		StatusIBCReceiveSuccess,
+		StatusIBCReceiveFailed,
+		StatusTokensUnlockedTimeout,
+		StatusTokensUnlockedAck,
+		StatusFailed,
+		StatusTimeout,
+	},
+	// a failed receive is followed by the acknowledgement (or the timeout)
+	// that unlocks the tokens on the source chain
+	StatusIBCReceiveFailed: {
+		StatusTokensUnlockedTimeout,
+		StatusTokensUnlockedAck,
+		StatusFailed,
+	},
+}
+
+// IsValid returns true if s is one of the ticket statuses.
+func (s TicketStatus) IsValid() bool {
+	for _, st := range allStatuses {
+		if st == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsTerminal returns true if no further transition is allowed from s.
+func (s TicketStatus) IsTerminal() bool {
+	_, ok := transitions[s]
+	return !ok
+}
+
+// TerminalStatuses returns the statuses no transition leaves.
+func TerminalStatuses() []TicketStatus {
+	var statuses []TicketStatus
+	for _, s := range allStatuses {
+		if s.IsTerminal() {
+			statuses = append(statuses, s)
+		}
+	}
+
+	return statuses
+}
+
+// CanTransitionTo returns true if a ticket in status s can move to next.
+func (s TicketStatus) CanTransitionTo(next TicketStatus) bool {
+	for _, t := range transitions[s] {
+		if t == next {
+			return true
+		}
+	}
+
+	return false
+}
+
+// knownStatus returns the status matching s regardless of case.
+func knownStatus(s TicketStatus) (TicketStatus, bool) {
+	for _, st := range allStatuses {
+		if strings.EqualFold(string(st), string(s)) {
+			return st, true
+		}
+	}
+
+	return "", false
+}
+
+func (s TicketStatus) String() string {
+	return string(s)
+}
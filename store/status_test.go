@@ -0,0 +1,71 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTicketStatusIsTerminal(t *testing.T) {
+	for _, s := range []TicketStatus{StatusPending, StatusTransit, StatusIBCReceiveFailed} {
+		require.False(t, s.IsTerminal(), s)
+	}
+
+	for _, s := range []TicketStatus{StatusComplete, StatusFailed, StatusIBCReceiveSuccess,
+		StatusTokensUnlockedTimeout, StatusTokensUnlockedAck, StatusTimeout} {
+		require.True(t, s.IsTerminal(), s)
+	}
+}
+
+func TestTicketStatusCanTransitionTo(t *testing.T) {
+	require.True(t, StatusPending.CanTransitionTo(StatusTransit))
+	require.True(t, StatusTransit.CanTransitionTo(StatusIBCReceiveFailed))
+	require.True(t, StatusIBCReceiveFailed.CanTransitionTo(StatusTokensUnlockedAck))
+	require.False(t, StatusPending.CanTransitionTo(StatusPending))
+	require.False(t, StatusPending.CanTransitionTo(StatusIBCReceiveSuccess))
+	require.False(t, StatusFailed.CanTransitionTo(StatusComplete))
+	require.False(t, StatusComplete.CanTransitionTo(StatusFailed))
+}
+
+func TestTicketStatusIsValid(t *testing.T) {
+	require.True(t, StatusPending.IsValid())
+	require.True(t, StatusTokensUnlockedAck.IsValid())
+	require.False(t, TicketStatus("unknown").IsValid())
+	require.False(t, TicketStatus("").IsValid())
+}
+
+func TestTerminalStatuses(t *testing.T) {
+	statuses := TerminalStatuses()
+	require.NotContains(t, statuses, StatusPending)
+	require.NotContains(t, statuses, StatusTransit)
+	require.NotContains(t, statuses, StatusIBCReceiveFailed)
+	require.Contains(t, statuses, StatusComplete)
+	require.Contains(t, statuses, StatusTokensUnlockedAck)
+	for _, s := range statuses {
+		require.True(t, s.IsTerminal())
+	}
+}
+
+func TestInvalidTransition(t *testing.T) {
+	defer ResetTestStore(mr, store)
+	key := GetKey(testChain, testTxHash)
+	require.NoError(t, store.CreateTicket(testChain, testTxHash, testOwner))
+	require.NoError(t, store.SetFailedWithErr(key, testErr, 123))
+	// a late completion must not override the failure
+	err := store.SetComplete(key, 124)
+	require.ErrorIs(t, err, ErrInvalidTransition)
+	ticket, err := store.Get(key)
+	require.NoError(t, err)
+	require.Equal(t, StatusFailed, ticket.Status)
+	require.Equal(t, testErr, ticket.Error)
+	// ack arriving after the receive on the counterparty chain
+	require.NoError(t, store.CreateTicketWithOptions(testChain, testTxHash, testOwner, CreateOptions{Force: true}))
+	require.NoError(t, store.SetInTransit(key, testDestChain, testSrcChannel,
+		testPktSeq, testTxHash, testChain, 123))
+	ibcKey := GetIBCKey(testDestChain, testSrcChannel, testPktSeq)
+	require.NoError(t, store.SetIbcReceived(ibcKey, testTxHash, testDestChain, 144))
+	require.ErrorIs(t, store.SetIbcAckUnlock(ibcKey, testTxHash, testChain, 145), ErrInvalidTransition)
+	ticket, err = store.Get(key)
+	require.NoError(t, err)
+	require.Equal(t, StatusIBCReceiveSuccess, ticket.Status)
+}
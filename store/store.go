@@ -2,44 +2,46 @@ package store
 
 import (
 	"context"
-	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
 	"time"
 
 	"github.com/alicebob/miniredis/v2"
-	sdk "github.com/cosmos/cosmos-sdk/types"
 	"github.com/go-redis/redis/v8"
 )
 
-const (
-	pending               = "pending"
-	transit               = "transit"
-	complete              = "complete"
-	failed                = "failed"
-	shadow                = "shadow"
-	ibcReceiveFailed      = "IBC_receive_failed"
-	ibcReceiveSuccess     = "IBC_receive_success"
-	tokensUnlockedTimeout = "Tokens_unlocked_timeout"
-	tokensUnlockedAck     = "Tokens_unlocked_ack"
-
-	// pool swap fees is stored only for one hour(12 * defaultExpiry)
-	poolExpiryMul = 12
-)
+const shadow = "shadow"
 
 var defaultExpiry = 300 * time.Second
 
 type Store struct {
-	Client        *redis.Client
+	Client        redis.UniversalClient
 	ConnectionURL string
-	Config        struct{ ExpiryTime time.Duration }
+	Config        struct {
+		// ExpiryTime is the base expiry Expiry falls back to.
+		ExpiryTime time.Duration
+		Expiry     ExpiryPolicy
+		// OwnerFallback identifies the owners that are not bech32
+		// addresses, RawOwner is used when nil.
+		OwnerFallback OwnerFallback
+	}
+
+	db int
+
+	// loads and refreshes deduplicate GetOrLoad calls
+	loads, refreshes loadGroup
+	// local is the in-process cache, nil unless enabled
+	local *localCache
+	// metrics are the prometheus metrics, nil unless enabled
+	metrics *metrics
 }
 
 type TxHashEntry struct {
 	Chain  string
-	Status string
+	Status TicketStatus
 	TxHash string
 }
 
@@ -47,9 +49,19 @@ type Ticket struct {
 	Owner    string        `json:"owner,omitempty"`
 	Info     string        `json:"info,omitempty"`
 	Height   int64         `json:"height,omitempty"`
-	Status   string        `json:"status,omitempty"`
+	Status   TicketStatus  `json:"status,omitempty"`
 	TxHashes []TxHashEntry `json:"tx_hashes,omitempty"`
 	Error    string        `json:"error,omitempty"`
+	// ErrorDetails is the structured form of Error, it is nil for tickets
+	// failed before it was recorded.
+	ErrorDetails *TicketError `json:"error_details,omitempty"`
+	// CreatedAt is nil for IBC packets, and for tickets created before it was
+	// recorded.
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	// Route lists the chains an IBC transfer goes through, the last one
+	// being its destination.
+	Route []string `json:"route,omitempty"`
+	Hops  []Hop    `json:"hops,omitempty"`
 }
 
 func (t *Ticket) UnmarshalBinary(data []byte) error {
@@ -59,285 +71,458 @@ func (t Ticket) MarshalBinary() (data []byte, err error) {
 	return json.Marshal(t)
 }
 
-// NewClient creates a new redis client
+// NewClient creates a new redis client connected to connUrl, which is either
+// a host:port address or a redis:// URL.
+// Use NewClientWithOptions for authentication, TLS and pool settings.
 func NewClient(connUrl string) (*Store, error) {
+	return NewClientWithOptions(Options{Addr: connUrl})
+}
 
-	var store Store
-
-	store.Client = redis.NewClient(&redis.Options{
-		Addr: connUrl,
-		DB:   0,
-	})
+// ErrTicketExists is returned, wrapped in a TicketExistsError, when creating
+// a ticket that already exists.
+var ErrTicketExists = errors.New("ticket already exists")
 
-	store.ConnectionURL = connUrl
+// TicketExistsError is returned when creating a ticket that already exists.
+type TicketExistsError struct {
+	Key string
+	// Ticket is the existing ticket, left untouched.
+	Ticket Ticket
+}
 
-	store.Config.ExpiryTime = defaultExpiry
+func (e *TicketExistsError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrTicketExists, e.Key)
+}
 
-	return &store, nil
+func (e *TicketExistsError) Unwrap() error {
+	return ErrTicketExists
+}
 
+// CreateOptions configure the creation of a ticket.
+type CreateOptions struct {
+	// Force replaces the existing ticket, if any, and its history.
+	Force bool
 }
 
+// CreateTicket creates a pending ticket for the transaction txHash on chain,
+// owned by owner. A *TicketExistsError is returned if the ticket exists, e.g.
+// when a client retries a request that succeeded.
 func (s *Store) CreateTicket(chain, txHash, owner string) error {
-	owner = hex.EncodeToString([]byte(owner))
-	data := Ticket{
-		Owner:  owner,
-		Status: pending,
-	}
+	return s.CreateTicketContext(context.Background(), chain, txHash, owner)
+}
 
-	key := GetKey(chain, txHash)
-	if err := s.CreateShadowKey(key); err != nil {
+// CreateTicketContext is like CreateTicket, with a context.
+func (s *Store) CreateTicketContext(ctx context.Context, chain, txHash, owner string) error {
+	return s.CreateTicketWithOptionsContext(ctx, chain, txHash, owner, CreateOptions{})
+}
+
+// CreateTicketWithOptions is like CreateTicket, configured by opts.
+func (s *Store) CreateTicketWithOptions(chain, txHash, owner string, opts CreateOptions) error {
+	return s.CreateTicketWithOptionsContext(context.Background(), chain, txHash, owner, opts)
+}
+
+// CreateTicketWithOptionsContext is like CreateTicketWithOptions, with a
+// context.
+func (s *Store) CreateTicketWithOptionsContext(ctx context.Context, chain, txHash, owner string, opts CreateOptions) error {
+	owner, err := s.ownerKey(owner)
+	if err != nil {
 		return err
 	}
 
-	if err := s.SetWithExpiry(key, data, 0); err != nil {
-		return err
+	now := time.Now().UTC()
+	data := Ticket{
+		Owner:     owner,
+		Status:    StatusPending,
+		CreatedAt: &now,
 	}
 
-	return s.sAdd(owner, key)
-}
+	key := GetKey(chain, txHash)
 
-func (s *Store) SetComplete(key string, height int64) error {
-	ticket, err := s.Get(key)
-	if err != nil {
+	// prev is the status of the ticket created over, if any
+	var prev TicketStatus
+	crossSlot := func(pipe redis.Pipeliner) {
+		pipe.Del(ctx, legacyShadowKey(key))
+		pipe.SAdd(ctx, owner, key)
+		countOpenTickets(ctx, pipe, prev, StatusPending)
+		s.publishEvent(ctx, pipe, TicketEvent{
+			Key:       key,
+			Owner:     owner,
+			NewStatus: StatusPending,
+		})
+	}
+
+	expiry := s.ticketExpiry(StatusPending)
+
+	// the transaction runs on the connection serving the ticket slot
+	err = s.Client.Watch(ctx, func(tx *redis.Tx) error {
+		var t Ticket
+		err := tx.Get(ctx, key).Scan(&t)
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return err
+		}
+		if err == nil && !opts.Force {
+			return &TicketExistsError{Key: key, Ticket: t}
+		}
+		prev = t.Status
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			if opts.Force {
+				pipe.Set(ctx, key, data, expiry)
+			} else {
+				pipe.SetNX(ctx, key, data, expiry)
+			}
+			pipe.Set(ctx, shadowKey(key), "", s.shadowExpiry())
+			// a ticket created over a previous one starts a new history
+			pipe.Del(ctx, historyKey(key))
+			appendHistory(ctx, pipe, key, TicketHistoryEntry{
+				Status:    StatusPending,
+				Chain:     chain,
+				TxHash:    txHash,
+				Timestamp: time.Now(),
+			}, expiry)
+			if !s.isCluster() {
+				crossSlot(pipe)
+			}
+			return nil
+		})
 		return err
+	}, key)
+
+	if errors.Is(err, redis.TxFailedErr) {
+		// the ticket was most likely created concurrently
+		if t, getErr := s.GetContext(ctx, key); getErr == nil && !opts.Force {
+			return &TicketExistsError{Key: key, Ticket: t}
+		}
+
+		return fmt.Errorf("%w: %s", ErrConflict, key)
 	}
 
-	if err := s.SetWithExpiry(key, Ticket{Status: complete,
-		Height: height}, 2); err != nil {
+	if err != nil {
 		return err
 	}
 
-	if err := s.DeleteShadowKey(key); err != nil {
-		return err
+	s.observeCreated(chain)
+	if !s.isCluster() {
+		return nil
 	}
 
-	return s.sRemove(ticket.Owner, key)
+	_, err = s.Client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		crossSlot(pipe)
+		return nil
+	})
+
+	return err
 }
 
-func (s *Store) SetIBCReceiveFailed(key string, txHashes []TxHashEntry, height int64) error {
-	if err := s.CreateShadowKey(key); err != nil {
-		return err
-	}
+func (s *Store) SetComplete(key string, height int64) error {
+	return s.SetCompleteContext(context.Background(), key, height)
+}
 
-	return s.SetWithExpiry(key, Ticket{Status: ibcReceiveFailed,
-		TxHashes: txHashes, Height: height}, 0)
+// SetCompleteContext is like SetComplete, with a context.
+func (s *Store) SetCompleteContext(ctx context.Context, key string, height int64) error {
+	return s.transition(ctx, ticketTransition{
+		key:  key,
+		next: StatusComplete,
+		update: func(t *Ticket) {
+			t.Height = height
+		},
+	})
 }
 
-func (s *Store) SetIBCReceiveSuccess(key, owner string, txHashes []TxHashEntry, height int64) error {
-	if err := s.SetWithExpiry(key, Ticket{
-		Status:   ibcReceiveSuccess,
-		TxHashes: txHashes,
-		Height:   height}, 2); err != nil {
-		return err
-	}
+func (s *Store) SetIBCReceiveFailed(key string, txHashes []TxHashEntry, height int64) error {
+	return s.SetIBCReceiveFailedContext(context.Background(), key, txHashes, height)
+}
+
+// SetIBCReceiveFailedContext is like SetIBCReceiveFailed, with a context.
+func (s *Store) SetIBCReceiveFailedContext(ctx context.Context, key string, txHashes []TxHashEntry, height int64) error {
+	return s.transition(ctx, ticketTransition{
+		key:  key,
+		next: StatusIBCReceiveFailed,
+		tx:   lastTxHash(txHashes),
+		update: func(t *Ticket) {
+			t.TxHashes = txHashes
+			t.Height = height
+		},
+	})
+}
 
-	if err := s.DeleteShadowKey(key); err != nil {
-		return err
-	}
+func (s *Store) SetIBCReceiveSuccess(key, owner string, txHashes []TxHashEntry, height int64) error {
+	return s.SetIBCReceiveSuccessContext(context.Background(), key, owner, txHashes, height)
+}
 
-	return s.sRemove(owner, key)
+// SetIBCReceiveSuccessContext is like SetIBCReceiveSuccess, with a context.
+func (s *Store) SetIBCReceiveSuccessContext(ctx context.Context, key, owner string, txHashes []TxHashEntry, height int64) error {
+	return s.setIBCResult(ctx, key, owner, StatusIBCReceiveSuccess, txHashes, height)
 }
 
 func (s *Store) SetUnlockTimeout(key, owner string, txHashes []TxHashEntry, height int64) error {
-	if err := s.SetWithExpiry(key, Ticket{Status: tokensUnlockedTimeout,
-		Height:   height,
-		TxHashes: txHashes}, 2); err != nil {
-		return err
-	}
-
-	if err := s.DeleteShadowKey(key); err != nil {
-		return err
-	}
+	return s.SetUnlockTimeoutContext(context.Background(), key, owner, txHashes, height)
+}
 
-	return s.sRemove(owner, key)
+// SetUnlockTimeoutContext is like SetUnlockTimeout, with a context.
+func (s *Store) SetUnlockTimeoutContext(ctx context.Context, key, owner string, txHashes []TxHashEntry, height int64) error {
+	return s.setIBCResult(ctx, key, owner, StatusTokensUnlockedTimeout, txHashes, height)
 }
 
 func (s *Store) SetUnlockAck(key, owner string, txHashes []TxHashEntry, height int64) error {
-	if err := s.SetWithExpiry(key, Ticket{Status: tokensUnlockedAck,
-		Height:   height,
-		TxHashes: txHashes}, 2); err != nil {
-		return err
-	}
+	return s.SetUnlockAckContext(context.Background(), key, owner, txHashes, height)
+}
+
+// SetUnlockAckContext is like SetUnlockAck, with a context.
+func (s *Store) SetUnlockAckContext(ctx context.Context, key, owner string, txHashes []TxHashEntry, height int64) error {
+	return s.setIBCResult(ctx, key, owner, StatusTokensUnlockedAck, txHashes, height)
+}
+
+func (s *Store) setIBCResult(ctx context.Context, key, owner string, status TicketStatus, txHashes []TxHashEntry, height int64) error {
+	return s.transition(ctx, ticketTransition{
+		key:  key,
+		next: status,
+		tx:   lastTxHash(txHashes),
+		update: func(t *Ticket) {
+			if owner != "" {
+				t.Owner = owner
+			}
+			t.TxHashes = txHashes
+			t.Height = height
+		},
+	})
+}
 
-	if err := s.DeleteShadowKey(key); err != nil {
-		return err
-	}
+func (s *Store) SetFailedWithErr(key, error string, height int64) error {
+	return s.SetFailedWithErrContext(context.Background(), key, error, height)
+}
 
-	return s.sRemove(owner, key)
+// SetFailedWithErrContext is like SetFailedWithErr, with a context.
+func (s *Store) SetFailedWithErrContext(ctx context.Context, key, error string, height int64) error {
+	return s.SetFailedWithTicketErrorContext(ctx, key, TicketError{
+		Category: ClassifyError("", 0, error),
+		Message:  error,
+	}, height)
 }
 
-func (s *Store) SetFailedWithErr(key, error string, height int64) error {
-	if !s.Exists(key) {
-		return fmt.Errorf("key doesn't exists")
-	}
+// SetFailedWithTicketError marks the ticket stored at key as failed with e.
+// e is classified with ClassifyError, and described by its category, unless
+// its Category and Message are set.
+func (s *Store) SetFailedWithTicketError(key string, e TicketError, height int64) error {
+	return s.SetFailedWithTicketErrorContext(context.Background(), key, e, height)
+}
 
-	prev, err := s.Get(key)
-	if err != nil {
-		return err
+// SetFailedWithTicketErrorContext is like SetFailedWithTicketError, with a
+// context.
+func (s *Store) SetFailedWithTicketErrorContext(ctx context.Context, key string, e TicketError, height int64) error {
+	if !s.ExistsContext(ctx, key) {
+		return fmt.Errorf("key doesn't exists")
 	}
 
-	data := Ticket{
-		Height: height,
-		Status: failed,
-		Error:  error,
-	}
+	return s.transition(ctx, failedTransition(key, e, height))
+}
 
-	if err := s.SetWithExpiry(key, data, 2); err != nil {
-		return err
-	}
+// SetTimeout marks the ticket stored at key as timed out.
+func (s *Store) SetTimeout(key string) error {
+	return s.SetTimeoutContext(context.Background(), key)
+}
 
-	return s.sRemove(prev.Owner, key)
+// SetTimeoutContext is like SetTimeout, with a context.
+func (s *Store) SetTimeoutContext(ctx context.Context, key string) error {
+	return s.transition(ctx, ticketTransition{
+		key:  key,
+		next: StatusTimeout,
+	})
 }
 
 func (s *Store) SetInTransit(key, destChain, sourceChannel, sendPacketSequence, txHash, chainName string, height int64) error {
+	return s.SetInTransitContext(context.Background(), key, destChain, sourceChannel, sendPacketSequence, txHash, chainName, height)
+}
+
+// SetInTransitContext is like SetInTransit, with a context.
+func (s *Store) SetInTransitContext(ctx context.Context, key, destChain, sourceChannel, sendPacketSequence, txHash, chainName string, height int64) error {
+	return s.SetInTransitRouteContext(ctx, key, []string{destChain}, sourceChannel, sendPacketSequence, txHash, chainName, height)
+}
 
-	if !s.Exists(key) {
+// SetInTransitRoute moves the ticket stored at key in transit to the last
+// chain of route, through the other ones. The packet sent to the first chain
+// of route is the first hop of the ticket, AddHop records the following
+// ones.
+func (s *Store) SetInTransitRoute(key string, route []string, sourceChannel, sendPacketSequence, txHash, chainName string, height int64) error {
+	return s.SetInTransitRouteContext(context.Background(), key, route, sourceChannel, sendPacketSequence, txHash, chainName, height)
+}
+
+// SetInTransitRouteContext is like SetInTransitRoute, with a context.
+func (s *Store) SetInTransitRouteContext(ctx context.Context, key string, route []string, sourceChannel, sendPacketSequence, txHash, chainName string, height int64) error {
+	if !s.ExistsContext(ctx, key) {
 		return fmt.Errorf("key doesn't exists")
 	}
 
-	if err := s.CreateShadowKey(key); err != nil {
-		return err
-	}
+	hop := sendHop(firstChain(route), sourceChannel, sendPacketSequence, TxHashEntry{
+		Chain:  chainName,
+		Status: StatusTransit,
+		TxHash: txHash,
+	})
 
-	ticket, err := s.Get(key)
+	tr, err := inTransitTransition(key, route, hop, height)
 	if err != nil {
 		return err
 	}
 
-	ticket.Status = transit
-	if err := s.SetWithExpiry(key, ticket, 2); err != nil {
-		return err
+	tr.extra = func(pipe redis.Pipeliner, t Ticket) {
+		pipe.Set(ctx, hop.PacketKey, packetTicket(key, t.Owner, hop.TxHashes[0]), s.packetExpiry())
 	}
 
-	newKey := GetIBCKey(destChain, sourceChannel, sendPacketSequence)
-
-	if err := s.SetWithExpiry(newKey, Ticket{Info: key,
-		Owner: ticket.Owner,
-		TxHashes: []TxHashEntry{{
-			Chain:  chainName,
-			Status: transit,
-			TxHash: txHash,
-		}}}, 2); err != nil {
-		return err
-	}
-
-	return nil
+	return s.transition(ctx, tr)
 }
 
-func (s *Store) SetIbcTimeoutUnlock(key, txHash, chainName string, height int64) error {
-
-	prev, err := s.Get(key)
+// AddHop records the packet forwarding the tokens of the packet stored at
+// prevKey to the next chain of the ticket route.
+func (s *Store) AddHop(prevKey, destChain, sourceChannel, sendPacketSequence, txHash, chainName string, height int64) error {
+	return s.AddHopContext(context.Background(), prevKey, destChain, sourceChannel, sendPacketSequence, txHash, chainName, height)
+}
 
+// AddHopContext is like AddHop, with a context.
+func (s *Store) AddHopContext(ctx context.Context, prevKey, destChain, sourceChannel, sendPacketSequence, txHash, chainName string, height int64) error {
+	prev, err := s.GetContext(ctx, prevKey)
 	if err != nil {
 		return err
 	}
 
-	txHashes := append(prev.TxHashes, TxHashEntry{
+	hop := sendHop(destChain, sourceChannel, sendPacketSequence, TxHashEntry{
 		Chain:  chainName,
-		Status: tokensUnlockedTimeout,
+		Status: StatusTransit,
 		TxHash: txHash,
 	})
 
-	return s.SetUnlockTimeout(prev.Info, prev.Owner, txHashes, height)
-}
-
-func (s *Store) SetIbcAckUnlock(key, txHash, chainName string, height int64) error {
-
-	prev, err := s.Get(key)
-
-	if err != nil {
-		return err
+	tr := addHopTransition(prevKey, prev, hop, height)
+	tr.extra = func(pipe redis.Pipeliner, t Ticket) {
+		pipe.Set(ctx, hop.PacketKey, packetTicket(prev.Info, t.Owner, hop.TxHashes[0]), s.packetExpiry())
 	}
 
-	txHashes := append(prev.TxHashes, TxHashEntry{
-		Chain:  chainName,
-		Status: tokensUnlockedAck,
-		TxHash: txHash,
-	})
-
-	return s.SetUnlockAck(prev.Info, prev.Owner, txHashes, height)
+	return s.transition(ctx, tr)
 }
 
-func (s *Store) SetIbcReceived(key, txHash, chainName string, height int64) error {
-
-	prev, err := s.Get(key)
-
+// setHopResult applies the outcome of the packet stored at packetKey to the
+// ticket it belongs to.
+func (s *Store) setHopResult(ctx context.Context, packetKey string, status TicketStatus, txHash, chainName string, height int64) error {
+	packet, err := s.GetContext(ctx, packetKey)
 	if err != nil {
 		return err
 	}
 
-	txHashes := append(prev.TxHashes, TxHashEntry{
+	return s.transition(ctx, hopResultTransition(packetKey, packet, TxHashEntry{
 		Chain:  chainName,
-		Status: ibcReceiveSuccess,
+		Status: status,
 		TxHash: txHash,
-	})
+	}, height))
+}
 
-	return s.SetIBCReceiveSuccess(prev.Info, prev.Owner, txHashes, height)
+func (s *Store) SetIbcTimeoutUnlock(key, txHash, chainName string, height int64) error {
+	return s.SetIbcTimeoutUnlockContext(context.Background(), key, txHash, chainName, height)
 }
 
-func (s *Store) SetIbcFailed(key, txHash, chainName string, height int64) error {
+// SetIbcTimeoutUnlockContext is like SetIbcTimeoutUnlock, with a context.
+func (s *Store) SetIbcTimeoutUnlockContext(ctx context.Context, key, txHash, chainName string, height int64) error {
+	return s.setHopResult(ctx, key, StatusTokensUnlockedTimeout, txHash, chainName, height)
+}
 
-	prev, err := s.Get(key)
+func (s *Store) SetIbcAckUnlock(key, txHash, chainName string, height int64) error {
+	return s.SetIbcAckUnlockContext(context.Background(), key, txHash, chainName, height)
+}
 
-	if err != nil {
-		return err
-	}
+// SetIbcAckUnlockContext is like SetIbcAckUnlock, with a context.
+func (s *Store) SetIbcAckUnlockContext(ctx context.Context, key, txHash, chainName string, height int64) error {
+	return s.setHopResult(ctx, key, StatusTokensUnlockedAck, txHash, chainName, height)
+}
 
-	txHashes := append(prev.TxHashes, TxHashEntry{
-		Chain:  chainName,
-		Status: ibcReceiveFailed,
-		TxHash: txHash,
-	})
-	return s.SetIBCReceiveFailed(prev.Info, txHashes, height)
+func (s *Store) SetIbcReceived(key, txHash, chainName string, height int64) error {
+	return s.SetIbcReceivedContext(context.Background(), key, txHash, chainName, height)
 }
 
-func (s *Store) SetPoolSwapFees(poolId, offerCoinAmount, offerCoinDenom string) error {
-	poolTicket := fmt.Sprintf("pool/%s/%d", poolId, time.Now().Unix())
+// SetIbcReceivedContext is like SetIbcReceived, with a context.
+func (s *Store) SetIbcReceivedContext(ctx context.Context, key, txHash, chainName string, height int64) error {
+	return s.setHopResult(ctx, key, StatusIBCReceiveSuccess, txHash, chainName, height)
+}
 
-	offerCoinAmountInt, ok := sdk.NewIntFromString(offerCoinAmount)
-	if !ok {
-		return fmt.Errorf("unable to convert offerCoinAmout to sdk Int")
-	}
+func (s *Store) SetIbcFailed(key, txHash, chainName string, height int64) error {
+	return s.SetIbcFailedContext(context.Background(), key, txHash, chainName, height)
+}
 
-	coin := sdk.NewCoin(offerCoinDenom, offerCoinAmountInt)
-	return s.SetWithExpiry(poolTicket, coin.String(), poolExpiryMul) //  mul is 12 as time out is set to 5minutes by default
+// SetIbcFailedContext is like SetIbcFailed, with a context.
+func (s *Store) SetIbcFailedContext(ctx context.Context, key, txHash, chainName string, height int64) error {
+	return s.setHopResult(ctx, key, StatusIBCReceiveFailed, txHash, chainName, height)
 }
 
 func (s *Store) CreateShadowKey(key string) error {
-	shadowKey := shadow + key
-	return s.SetWithExpiry(shadowKey, "", 1)
+	return s.CreateShadowKeyContext(context.Background(), key)
+}
+
+// CreateShadowKeyContext is like CreateShadowKey, with a context.
+func (s *Store) CreateShadowKeyContext(ctx context.Context, key string) error {
+	return s.SetWithExpiryTimeContext(ctx, shadowKey(key), "", s.shadowExpiry())
 }
 
 func (s *Store) Exists(key string) bool {
-	exists, _ := s.Client.Exists(context.Background(), key).Result()
+	return s.ExistsContext(context.Background(), key)
+}
+
+// ExistsContext is like Exists, with a context.
+func (s *Store) ExistsContext(ctx context.Context, key string) bool {
+	exists, _ := s.Client.Exists(ctx, key).Result()
 
 	return exists == 1
 }
 
 func (s *Store) SetWithExpiry(key string, value interface{}, mul int64) error {
-	return s.Client.Set(context.Background(), key, value, time.Duration(mul)*(s.Config.ExpiryTime)).Err()
+	return s.SetWithExpiryContext(context.Background(), key, value, mul)
+}
+
+// SetWithExpiryContext is like SetWithExpiry, with a context.
+func (s *Store) SetWithExpiryContext(ctx context.Context, key string, value interface{}, mul int64) error {
+	return s.Client.Set(ctx, key, value, time.Duration(mul)*(s.Config.ExpiryTime)).Err()
 }
 
 func (s *Store) SetWithExpiryTime(key string, value interface{}, duration time.Duration) error {
-	return s.Client.Set(context.Background(), key, value, duration).Err()
+	return s.SetWithExpiryTimeContext(context.Background(), key, value, duration)
+}
+
+// SetWithExpiryTimeContext is like SetWithExpiryTime, with a context.
+func (s *Store) SetWithExpiryTimeContext(ctx context.Context, key string, value interface{}, duration time.Duration) error {
+	return s.Client.Set(ctx, key, value, duration).Err()
 }
 
 func (s *Store) Get(key string) (Ticket, error) {
+	return s.GetContext(context.Background(), key)
+}
+
+// GetContext is like Get, with a context.
+func (s *Store) GetContext(ctx context.Context, key string) (Ticket, error) {
 	var res Ticket
-	if err := s.Client.Get(context.Background(), key).Scan(&res); err != nil {
+	if err := s.Client.Get(ctx, key).Scan(&res); err != nil {
 		return Ticket{}, err
 	}
 
 	return res, nil
 }
 
+// GetUserTickets returns the tx hashes of the tickets owned by user, grouped
+// by chain. Use ListUserTickets to read the tickets themselves.
 func (s *Store) GetUserTickets(user string) (map[string][]string, error) {
-	var keys []string
-	keys, err := s.sMembers(hex.EncodeToString([]byte(user)))
+	return s.GetUserTicketsContext(context.Background(), user)
+}
+
+// GetUserTicketsContext is like GetUserTickets, with a context.
+func (s *Store) GetUserTicketsContext(ctx context.Context, user string) (map[string][]string, error) {
+	owner, err := s.ownerKey(user)
+	if err != nil {
+		return map[string][]string{}, err
+	}
+
+	keys, err := s.sMembers(ctx, owner)
 	if err != nil {
 		return map[string][]string{}, err
 	}
 
+	return groupTicketKeys(keys)
+}
+
+// groupTicketKeys groups the tx hashes of the ticket keys by chain.
+func groupTicketKeys(keys []string) (map[string][]string, error) {
 	res := make(map[string][]string)
 	for _, key := range keys {
 		s := strings.Split(key, "/")
@@ -351,8 +536,14 @@ func (s *Store) GetUserTickets(user string) (map[string][]string, error) {
 	return res, nil
 }
 
+// GetPools returns the JSON stored by SetPools. Use CachedPools to decode it.
 func (s *Store) GetPools() ([]byte, error) {
-	bz, err := s.Client.Get(context.Background(), "pools").Bytes()
+	return s.GetPoolsContext(context.Background())
+}
+
+// GetPoolsContext is like GetPools, with a context.
+func (s *Store) GetPoolsContext(ctx context.Context) ([]byte, error) {
+	bz, _, err := s.readCached(ctx, poolsKey)
 	if err != nil {
 		return nil, fmt.Errorf("cannot fetch pools from cache, %w", err)
 	}
@@ -360,8 +551,14 @@ func (s *Store) GetPools() ([]byte, error) {
 	return bz, nil
 }
 
+// GetParams returns the JSON stored by SetParams. Use CachedParams to decode it.
 func (s *Store) GetParams() ([]byte, error) {
-	bz, err := s.Client.Get(context.Background(), "params").Bytes()
+	return s.GetParamsContext(context.Background())
+}
+
+// GetParamsContext is like GetParams, with a context.
+func (s *Store) GetParamsContext(ctx context.Context) ([]byte, error) {
+	bz, _, err := s.readCached(ctx, paramsKey)
 	if err != nil {
 		return bz, fmt.Errorf("cannot fetch params from cache, %w", err)
 	}
@@ -369,8 +566,14 @@ func (s *Store) GetParams() ([]byte, error) {
 	return bz, nil
 }
 
+// GetSupply returns the JSON stored by SetSupply. Use CachedSupply to decode it.
 func (s *Store) GetSupply() ([]byte, error) {
-	bz, err := s.Client.Get(context.Background(), "supply").Bytes()
+	return s.GetSupplyContext(context.Background())
+}
+
+// GetSupplyContext is like GetSupply, with a context.
+func (s *Store) GetSupplyContext(ctx context.Context) ([]byte, error) {
+	bz, _, err := s.readCached(ctx, supplyKey)
 	if err != nil {
 		return nil, fmt.Errorf("cannot fetch total supply from cache, %w", err)
 	}
@@ -378,8 +581,14 @@ func (s *Store) GetSupply() ([]byte, error) {
 	return bz, nil
 }
 
+// GetNodeInfo returns the JSON stored by SetNodeInfo. Use CachedNodeInfo to decode it.
 func (s *Store) GetNodeInfo() ([]byte, error) {
-	bz, err := s.Client.Get(context.Background(), "node_info").Bytes()
+	return s.GetNodeInfoContext(context.Background())
+}
+
+// GetNodeInfoContext is like GetNodeInfo, with a context.
+func (s *Store) GetNodeInfoContext(ctx context.Context) ([]byte, error) {
+	bz, _, err := s.readCached(ctx, nodeInfoKey)
 	if err != nil {
 		return nil, fmt.Errorf("cannot fetch node info from cache, %w", err)
 	}
@@ -388,20 +597,32 @@ func (s *Store) GetNodeInfo() ([]byte, error) {
 }
 
 func (s *Store) Delete(key string) error {
-	return s.Client.Del(context.Background(), key).Err()
+	return s.DeleteContext(context.Background(), key)
+}
+
+// DeleteContext is like Delete, with a context.
+func (s *Store) DeleteContext(ctx context.Context, key string) error {
+	return s.Client.Del(ctx, key).Err()
 }
 
 func (s *Store) DeleteShadowKey(key string) error {
-	shadowKey := shadow + key
-	return s.Delete(shadowKey)
+	return s.DeleteShadowKeyContext(context.Background(), key)
 }
-func (s *Store) sAdd(user, key string) error {
-	return s.Client.SAdd(context.Background(), user, key).Err()
+
+// DeleteShadowKeyContext is like DeleteShadowKey, with a context.
+func (s *Store) DeleteShadowKeyContext(ctx context.Context, key string) error {
+	_, err := s.Client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, shadowKey(key))
+		pipe.Del(ctx, legacyShadowKey(key))
+		return nil
+	})
+
+	return err
 }
 
-func (s *Store) sMembers(user string) ([]string, error) {
+func (s *Store) sMembers(ctx context.Context, user string) ([]string, error) {
 	var keys []string
-	err := s.Client.SMembers(context.Background(), user).ScanSlice(&keys)
+	err := s.Client.SMembers(ctx, user).ScanSlice(&keys)
 	if err != nil {
 		return []string{}, err
 	}
@@ -409,79 +630,92 @@ func (s *Store) sMembers(user string) ([]string, error) {
 	return keys, err
 }
 
-func (s *Store) sRemove(user, key string) error {
-	return s.Client.SRem(context.Background(), user, key).Err()
+// GetMany returns the values stored at keys, read in a single round trip
+// per server, along with the keys that don't exist.
+func (s *Store) GetMany(keys []string) (map[string]string, []string, error) {
+	return s.GetManyContext(context.Background(), keys)
 }
 
-func (s *Store) GetSwapFees(poolId string) (sdk.Coins, error) {
-	values, err := s.scan(fmt.Sprintf("pool/%s/*", poolId))
-	if err != nil {
-		return sdk.Coins{}, err
-	}
-
-	var coins sdk.Coins
-	for _, value := range values {
-		coin, err := sdk.ParseCoinNormalized(value)
-		if err != nil {
-			return sdk.Coins{}, err
-		}
-
-		coins = coins.Add(coin)
-	}
-
-	return coins, nil
+// GetManyContext is like GetMany, with a context.
+func (s *Store) GetManyContext(ctx context.Context, keys []string) (map[string]string, []string, error) {
+	return getMany(ctx, s.Client, keys)
 }
 
-func (s *Store) scan(prefix string) ([]string, error) {
-	keys, nextCur, err := s.Client.Scan(context.Background(), 0, prefix, 10).Result()
-	if err != nil {
-		return nil, err
-	}
+// GetManyTickets is like GetMany, decoding the values as tickets.
+func (s *Store) GetManyTickets(keys []string) (map[string]Ticket, []string, error) {
+	return s.GetManyTicketsContext(context.Background(), keys)
+}
 
-	values, err := s.getValues(keys)
+// GetManyTicketsContext is like GetManyTickets, with a context.
+func (s *Store) GetManyTicketsContext(ctx context.Context, keys []string) (map[string]Ticket, []string, error) {
+	values, missing, err := getMany(ctx, s.Client, keys)
 	if err != nil {
-		return nil, err
-	}
-
-	if nextCur == 0 {
-		return values, nil
+		return nil, nil, err
 	}
 
-	for nextCur != 0 {
-		var nextKeys []string
-		nextKeys, nextCur, err = s.Client.Scan(context.Background(), nextCur, prefix, 100).Result()
-		if err != nil {
-			return nil, err
-		}
-
-		newValues, err := s.getValues(nextKeys)
-		if err != nil {
-			return nil, err
+	tickets := make(map[string]Ticket, len(values))
+	for k, v := range values {
+		var t Ticket
+		if err := t.UnmarshalBinary([]byte(v)); err != nil {
+			return nil, nil, fmt.Errorf("cannot decode ticket %s, %w", k, err)
 		}
 
-		values = append(values, newValues...)
+		tickets[k] = t
 	}
-	return values, nil
+
+	return tickets, missing, nil
 }
 
-func (s *Store) getValues(keys []string) ([]string, error) {
-	values := make([]string, 0, len(keys))
+// getMany pipelines a GET per key rather than issuing a MGET, which Redis
+// Cluster rejects when keys span several slots.
+func getMany(ctx context.Context, client redis.UniversalClient, keys []string) (map[string]string, []string, error) {
+	values := make(map[string]string, len(keys))
+	if len(keys) == 0 {
+		return values, nil, nil
+	}
 
-	for _, k := range keys {
-		value, err := s.Client.Get(context.Background(), k).Result()
-		if err != nil {
-			return nil, err
+	cmds := make([]*redis.StringCmd, len(keys))
+	_, err := client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, k := range keys {
+			cmds[i] = pipe.Get(ctx, k)
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, nil, err
+	}
+
+	var missing []string
+	for i, cmd := range cmds {
+		v, err := cmd.Result()
+		switch {
+		case errors.Is(err, redis.Nil):
+			missing = append(missing, keys[i])
+		case err != nil:
+			return nil, nil, err
+		default:
+			values[keys[i]] = v
 		}
-		values = append(values, value)
 	}
 
-	return values, nil
+	return values, missing, nil
 }
 
 func GetKey(chain, txHash string) string {
 	return fmt.Sprintf("%s/%s", chain, txHash)
 }
 
+func shadowKey(key string) string {
+	return hashTagged(shadow, key)
+}
+
+// legacyShadowKey returns the shadow key of key as written before cluster
+// support. Such keys live outside of the ticket slot, and are deleted when the
+// ticket is written so that their expiry doesn't time out a healthy ticket.
+func legacyShadowKey(key string) string {
+	return shadow + key
+}
+
 func GetIBCKey(chain, packetSrcChannel, packetSequence string) string {
 	return fmt.Sprintf("%s-%s-%s", chain, packetSrcChannel, packetSequence)
 }
@@ -501,5 +735,5 @@ func SetupTestStore() (*miniredis.Miniredis, *Store) {
 }
 
 func ResetTestStore(m *miniredis.Miniredis, s *Store) {
-	m.DB(s.Client.Options().DB).FlushDB()
+	m.DB(s.db).FlushDB()
 }
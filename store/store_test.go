@@ -1,12 +1,15 @@
 package store
 
 import (
+	"context"
+	"fmt"
 	"os"
 	"testing"
 	"time"
 
 	"github.com/alicebob/miniredis/v2"
 	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/go-redis/redis/v8"
 	"github.com/stretchr/testify/require"
 )
 
@@ -33,7 +36,7 @@ func TestMain(m *testing.M) {
 }
 
 func getShadowKey(key string) string {
-	return shadow + key
+	return shadowKey(key)
 }
 
 func TestCreateTicket(t *testing.T) {
@@ -51,7 +54,8 @@ func TestCreateTicket(t *testing.T) {
 	// get created ticket details
 	ticket, err := store.Get(key)
 	require.NoError(t, err)
-	require.Equal(t, pending, ticket.Status)
+	require.Equal(t, StatusPending, ticket.Status)
+	require.NotNil(t, ticket.CreatedAt)
 }
 
 func TestSetComplete(t *testing.T) {
@@ -65,7 +69,7 @@ func TestSetComplete(t *testing.T) {
 	// get updated ticket details of key
 	ticket, err := store.Get(key)
 	require.NoError(t, err)
-	require.Equal(t, complete, ticket.Status)
+	require.Equal(t, StatusComplete, ticket.Status)
 	// check shadow key deleted
 	require.False(t, store.Exists(getShadowKey(key)))
 	// get all tickets of testOwner
@@ -90,13 +94,17 @@ func TestSetInTransit(t *testing.T) {
 	// get updated ticket details of key
 	ticket, err := store.Get(key)
 	require.NoError(t, err)
-	require.Equal(t, transit, ticket.Status)
+	require.Equal(t, StatusTransit, ticket.Status)
 	newKey := GetIBCKey(testDestChain, testSrcChannel, testPktSeq)
 	require.True(t, store.Exists(newKey))
 	// get created ticket details of new key
 	newKeyTicket, err := store.Get(newKey)
 	require.NoError(t, err)
 	require.Len(t, newKeyTicket.TxHashes, 1)
+	// packets have no creation time
+	raw, err := mr.Get(newKey)
+	require.NoError(t, err)
+	require.NotContains(t, raw, "created_at")
 }
 
 func TestSetIbcReceived(t *testing.T) {
@@ -117,7 +125,7 @@ func TestSetIbcReceived(t *testing.T) {
 	// get updated ticket details of key
 	ticket, err := store.Get(key)
 	require.NoError(t, err)
-	require.Equal(t, ibcReceiveSuccess, ticket.Status)
+	require.Equal(t, StatusIBCReceiveSuccess, ticket.Status)
 	require.Len(t, ticket.TxHashes, 2)
 	// check shadow key deleted
 	require.False(t, store.Exists(getShadowKey(key)))
@@ -146,7 +154,7 @@ func TestSetIbcFailed(t *testing.T) {
 	// get updated ticket details of key
 	ticket, err := store.Get(key)
 	require.NoError(t, err)
-	require.Equal(t, ibcReceiveFailed, ticket.Status)
+	require.Equal(t, StatusIBCReceiveFailed, ticket.Status)
 	require.Len(t, ticket.TxHashes, 2)
 	// check shadow key still exists
 	require.True(t, store.Exists(getShadowKey(key)))
@@ -170,7 +178,7 @@ func TestSetIbcTimeoutUnlock(t *testing.T) {
 	// get updated ticket details of key
 	ticket, err := store.Get(key)
 	require.NoError(t, err)
-	require.Equal(t, tokensUnlockedTimeout, ticket.Status)
+	require.Equal(t, StatusTokensUnlockedTimeout, ticket.Status)
 	require.Len(t, ticket.TxHashes, 2)
 	// check shadow key deleted
 	require.False(t, store.Exists(getShadowKey(key)))
@@ -199,7 +207,7 @@ func TestSetIbcAckUnlock(t *testing.T) {
 	// get updated ticket details of key
 	ticket, err := store.Get(key)
 	require.NoError(t, err)
-	require.Equal(t, tokensUnlockedAck, ticket.Status)
+	require.Equal(t, StatusTokensUnlockedAck, ticket.Status)
 	require.Len(t, ticket.TxHashes, 2)
 	// check shadow key deleted
 	require.False(t, store.Exists(getShadowKey(key)))
@@ -222,7 +230,7 @@ func TestSetFailedWithErr(t *testing.T) {
 	// get updated ticket details of key
 	ticket, err := store.Get(key)
 	require.NoError(t, err)
-	require.Equal(t, failed, ticket.Status)
+	require.Equal(t, StatusFailed, ticket.Status)
 	require.Equal(t, testErr, ticket.Error)
 	// get all tickets of testOwner
 	tickets, err := store.GetUserTickets(testOwner)
@@ -249,6 +257,172 @@ func TestSetPoolSwapFees(t *testing.T) {
 	require.Equal(t, sdk.Coins{sdk.NewCoin(testDenom, testAmountInt)}.String(), fees.String())
 }
 
+func TestSwapFeesSameSecond(t *testing.T) {
+	defer ResetTestStore(mr, store)
+	// identical fees paid within the same second are all recorded
+	for i := 0; i < 250; i++ {
+		require.NoError(t, store.SetPoolSwapFees("7", "2", "stake"))
+	}
+	fees, err := store.GetSwapFees("7")
+	require.NoError(t, err)
+	require.Equal(t, "500stake", fees.String())
+}
+
+func TestSwapFeesRetention(t *testing.T) {
+	defer ResetTestStore(mr, store)
+	old := time.Now().Add(-2 * store.swapFeesExpiry())
+	require.NoError(t, store.Client.ZAdd(context.Background(), swapFeesKey("7"), &redis.Z{
+		Score:  float64(old.UnixNano() / int64(time.Millisecond)),
+		Member: "old:100stake",
+	}).Err())
+	// fees older than the retention are not counted
+	fees, err := store.GetSwapFees("7")
+	require.NoError(t, err)
+	require.True(t, fees.IsZero())
+	// and are trimmed on write
+	require.NoError(t, store.SetPoolSwapFees("7", "1", "stake"))
+	fees, err = store.GetSwapFees("7")
+	require.NoError(t, err)
+	require.Equal(t, "1stake", fees.String())
+	members, err := store.Client.ZRange(context.Background(), swapFeesKey("7"), 0, -1).Result()
+	require.NoError(t, err)
+	require.Len(t, members, 1)
+	require.True(t, mr.TTL(swapFeesKey("7")) > 0)
+}
+
+func TestMigrateSwapFees(t *testing.T) {
+	defer ResetTestStore(mr, store)
+	now := time.Now()
+	// legacy fees, one of them older than the retention
+	legacy := map[string]string{
+		fmt.Sprintf("pool/7/%d", now.Add(-time.Minute).Unix()):              "3stake",
+		fmt.Sprintf("pool/7/%d", now.Add(-2*time.Minute).Unix()):            "4stake",
+		fmt.Sprintf("pool/8/%d", now.Add(-time.Minute).Unix()):              "5atom",
+		fmt.Sprintf("pool/7/%d", now.Add(-2*store.swapFeesExpiry()).Unix()): "100stake",
+	}
+	for k, v := range legacy {
+		require.NoError(t, mr.Set(k, v))
+	}
+	require.NoError(t, mr.Set("pool/7/other", "1stake"))
+	require.NoError(t, store.SetPoolSwapFees("7", "1", "stake"))
+
+	moved, err := store.MigrateSwapFees()
+	require.NoError(t, err)
+	require.Equal(t, 3, moved)
+	for k := range legacy {
+		require.False(t, mr.Exists(k))
+	}
+	require.True(t, mr.Exists("pool/7/other"))
+
+	fees, err := store.GetSwapFees("7")
+	require.NoError(t, err)
+	require.Equal(t, "8stake", fees.String())
+	fees, err = store.GetSwapFees("8")
+	require.NoError(t, err)
+	require.Equal(t, "5atom", fees.String())
+
+	// moved fees keep their time
+	fees, err = store.SwapFeesBetween("7", now.Add(-150*time.Second), now.Add(-30*time.Second))
+	require.NoError(t, err)
+	require.Equal(t, "7stake", fees.String())
+
+	moved, err = store.MigrateSwapFees()
+	require.NoError(t, err)
+	require.Zero(t, moved)
+}
+
+func addSwapFee(t *testing.T, poolId, member string, at time.Time) {
+	t.Helper()
+	require.NoError(t, store.Client.ZAdd(context.Background(), swapFeesKey(poolId), &redis.Z{
+		Score:  float64(toMillis(at)),
+		Member: member,
+	}).Err())
+}
+
+func TestSwapFeesBetween(t *testing.T) {
+	defer ResetTestStore(mr, store)
+	now := time.Now()
+	addSwapFee(t, "7", "a:1stake", now.Add(-50*time.Minute))
+	addSwapFee(t, "7", "b:2stake", now.Add(-30*time.Minute))
+	addSwapFee(t, "7", "c:4uatom", now.Add(-10*time.Minute))
+
+	fees, err := store.SwapFeesBetween("7", now.Add(-40*time.Minute), now)
+	require.NoError(t, err)
+	require.Equal(t, "2stake,4uatom", fees.String())
+
+	fees, err = store.SwapFeesBetween("7", now.Add(-time.Hour), now.Add(-30*time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, "1stake", fees.String())
+
+	_, err = store.SwapFeesBetween("7", now, now.Add(-time.Hour))
+	require.Error(t, err)
+}
+
+func TestSwapFeesBucketed(t *testing.T) {
+	defer ResetTestStore(mr, store)
+	now := time.Now()
+	addSwapFee(t, "7", "a:1stake", now.Add(-140*time.Minute))
+	addSwapFee(t, "7", "b:2stake", now.Add(-90*time.Minute))
+	addSwapFee(t, "7", "c:4stake", now.Add(-70*time.Minute))
+	addSwapFee(t, "7", "d:8stake", now.Add(-time.Minute))
+
+	buckets, err := store.SwapFeesBucketed("7", 150*time.Minute, time.Hour)
+	require.NoError(t, err)
+	require.Len(t, buckets, 3)
+	// the oldest bucket is only half an hour long
+	require.Equal(t, 30*time.Minute, buckets[0].End.Sub(buckets[0].Start))
+	require.Equal(t, "1stake", buckets[0].Fees.String())
+	require.Equal(t, "6stake", buckets[1].Fees.String())
+	require.Equal(t, "8stake", buckets[2].Fees.String())
+	require.Equal(t, buckets[1].End, buckets[2].Start)
+
+	_, err = store.SwapFeesBucketed("7", time.Hour, 2*time.Hour)
+	require.Error(t, err)
+	_, err = store.SwapFeesBucketed("7", time.Hour, 0)
+	require.Error(t, err)
+}
+
+func TestSwapFeesConfigurableRetention(t *testing.T) {
+	defer ResetTestStore(mr, store)
+	defer func() { store.Config.Expiry.SwapFees = 0 }()
+	store.Config.Expiry.SwapFees = 7 * 24 * time.Hour
+
+	addSwapFee(t, "7", "a:1stake", time.Now().Add(-3*24*time.Hour))
+	require.NoError(t, store.SetPoolSwapFees("7", "2", "stake"))
+	// the 3 days old fee is kept
+	fees, err := store.GetSwapFees("7")
+	require.NoError(t, err)
+	require.Equal(t, "3stake", fees.String())
+	require.Equal(t, 7*24*time.Hour, mr.TTL(swapFeesKey("7")))
+}
+
+func TestGetMany(t *testing.T) {
+	defer ResetTestStore(mr, store)
+	require.NoError(t, store.CreateTicket(testChain, testTxHash, testOwner))
+	key := GetKey(testChain, testTxHash)
+	require.NoError(t, store.SetWithExpiry("raw", "value", 0))
+
+	values, missing, err := store.GetMany([]string{"raw", "missing", key})
+	require.NoError(t, err)
+	require.Equal(t, []string{"missing"}, missing)
+	require.Len(t, values, 2)
+	require.Equal(t, "value", values["raw"])
+
+	tickets, missing, err := store.GetManyTickets([]string{key, "missing"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"missing"}, missing)
+	require.Equal(t, StatusPending, tickets[key].Status)
+
+	// values that are not tickets
+	_, _, err = store.GetManyTickets([]string{"raw"})
+	require.Error(t, err)
+
+	values, missing, err = store.GetMany(nil)
+	require.NoError(t, err)
+	require.Empty(t, values)
+	require.Empty(t, missing)
+}
+
 func TestBlocks(t *testing.T) {
 	defer ResetTestStore(mr, store)
 	blocks := NewBlocks(store)
@@ -0,0 +1,438 @@
+// Package storetest implements the tests every store.TicketStore passes.
+package storetest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/emerishq/emeris-utils/store"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testChain      = "cosmos-hub"
+	testTxHash     = "918DC23785CABA3EE4E4A59321E679F8B7A2E27C9DFB165B3B6D22EF23017264"
+	testOwner      = "cosmos1l2lepugxx5heetsl2cs74e2sy0uqxv390as7zw"
+	testDestChain  = "cosmos-hub-2"
+	testSrcChannel = "channel-1"
+	testPktSeq     = "1"
+	testErr        = "dummy error"
+	testLastChain  = "osmosis"
+
+	// testOsmoOwner is the address of testOwner on osmosis, testOwnerKey
+	// their address bytes
+	testOsmoOwner = "osmo1l2lepugxx5heetsl2cs74e2sy0uqxv398xrw5u"
+	testOwnerKey  = "fabf90f106352f9cae1f5621eae55023f8033225"
+)
+
+// NewStoreFunc returns an empty TicketStore for a test.
+type NewStoreFunc func(t *testing.T) store.TicketStore
+
+// Run runs the TicketStore tests against the stores returned by newStore.
+func Run(t *testing.T, newStore NewStoreFunc) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s store.TicketStore)
+	}{
+		{"CreateTicket", testCreateTicket},
+		{"OwnerPrefixes", testOwnerPrefixes},
+		{"TicketExists", testTicketExists},
+		{"SetComplete", testSetComplete},
+		{"SetFailedWithErr", testSetFailedWithErr},
+		{"SetFailedWithTicketError", testSetFailedWithTicketError},
+		{"SetTimeout", testSetTimeout},
+		{"IbcReceived", testIbcReceived},
+		{"IbcFailedAckUnlock", testIbcFailedAckUnlock},
+		{"IbcTimeoutUnlock", testIbcTimeoutUnlock},
+		{"InvalidTransition", testInvalidTransition},
+		{"MissingTicket", testMissingTicket},
+		{"History", testHistory},
+		{"ListUserTickets", testListUserTickets},
+		{"MultiHop", testMultiHop},
+		{"MultiHopFailure", testMultiHopFailure},
+		{"InvalidHop", testInvalidHop},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newStore(t))
+		})
+	}
+}
+
+func createTicket(t *testing.T, s store.TicketStore) string {
+	t.Helper()
+	require.NoError(t, s.CreateTicketContext(context.Background(), testChain, testTxHash, testOwner))
+	return store.GetKey(testChain, testTxHash)
+}
+
+func requireStatus(t *testing.T, s store.TicketStore, key string, status store.TicketStatus) store.Ticket {
+	t.Helper()
+	ticket, err := s.GetContext(context.Background(), key)
+	require.NoError(t, err)
+	require.Equal(t, status, ticket.Status)
+	return ticket
+}
+
+func requireOwned(t *testing.T, s store.TicketStore, owned bool) {
+	t.Helper()
+	tickets, err := s.GetUserTicketsContext(context.Background(), testOwner)
+	require.NoError(t, err)
+	if owned {
+		require.Equal(t, []string{testTxHash}, tickets[testChain])
+	} else {
+		require.Empty(t, tickets[testChain])
+	}
+}
+
+func setInTransit(t *testing.T, s store.TicketStore, key string) string {
+	t.Helper()
+	require.NoError(t, s.SetInTransitContext(context.Background(), key, testDestChain, testSrcChannel, testPktSeq, testTxHash, testChain, 10))
+	return store.GetIBCKey(testDestChain, testSrcChannel, testPktSeq)
+}
+
+// setInTransitRoute sends the ticket at key to testLastChain through
+// testDestChain, and returns the packet keys of both hops.
+func setInTransitRoute(t *testing.T, s store.TicketStore, key string) (string, string) {
+	t.Helper()
+	ctx := context.Background()
+	require.NoError(t, s.SetInTransitRouteContext(ctx, key, []string{testDestChain, testLastChain}, testSrcChannel, testPktSeq, testTxHash, testChain, 10))
+	firstKey := store.GetIBCKey(testDestChain, testSrcChannel, testPktSeq)
+
+	require.NoError(t, s.SetIbcReceivedContext(ctx, firstKey, "RECV", testDestChain, 11))
+	require.NoError(t, s.AddHopContext(ctx, firstKey, testLastChain, "channel-2", "5", "FORWARD", testDestChain, 11))
+	return firstKey, store.GetIBCKey(testLastChain, "channel-2", "5")
+}
+
+func testCreateTicket(t *testing.T, s store.TicketStore) {
+	key := createTicket(t, s)
+	ticket := requireStatus(t, s, key, store.StatusPending)
+	require.Equal(t, testOwnerKey, ticket.Owner)
+	requireOwned(t, s, true)
+}
+
+func testTicketExists(t *testing.T, s store.TicketStore) {
+	ctx := context.Background()
+	key := createTicket(t, s)
+	setInTransit(t, s, key)
+
+	// a retried creation leaves the ticket untouched
+	err := s.CreateTicketContext(ctx, testChain, testTxHash, testOwner)
+	require.ErrorIs(t, err, store.ErrTicketExists)
+	var existsErr *store.TicketExistsError
+	require.ErrorAs(t, err, &existsErr)
+	require.Equal(t, key, existsErr.Key)
+	require.Equal(t, store.StatusTransit, existsErr.Ticket.Status)
+	requireStatus(t, s, key, store.StatusTransit)
+
+	history, err := s.TicketHistoryContext(ctx, key)
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+
+	require.NoError(t, s.CreateTicketWithOptionsContext(ctx, testChain, testTxHash, testOwner, store.CreateOptions{Force: true}))
+	requireStatus(t, s, key, store.StatusPending)
+}
+
+func testOwnerPrefixes(t *testing.T, s store.TicketStore) {
+	ctx := context.Background()
+	require.NoError(t, s.CreateTicketContext(ctx, testChain, testTxHash, testOwner))
+	require.NoError(t, s.CreateTicketContext(ctx, testLastChain, "OSMO", testOsmoOwner))
+	require.NoError(t, s.CreateTicketContext(ctx, testChain, "RAW", "not-an-address"))
+
+	for _, owner := range []string{testOwner, testOsmoOwner} {
+		tickets, err := s.GetUserTicketsContext(ctx, owner)
+		require.NoError(t, err)
+		require.Equal(t, map[string][]string{
+			testChain:     {testTxHash},
+			testLastChain: {"OSMO"},
+		}, tickets)
+	}
+
+	tickets, err := s.GetUserTicketsContext(ctx, "not-an-address")
+	require.NoError(t, err)
+	require.Equal(t, []string{"RAW"}, tickets[testChain])
+
+	ticket, err := s.GetContext(ctx, store.GetKey(testChain, "RAW"))
+	require.NoError(t, err)
+	require.Equal(t, fmt.Sprintf("%x", "not-an-address"), ticket.Owner)
+}
+
+func testSetComplete(t *testing.T, s store.TicketStore) {
+	key := createTicket(t, s)
+	require.NoError(t, s.SetCompleteContext(context.Background(), key, 12))
+	ticket := requireStatus(t, s, key, store.StatusComplete)
+	require.Equal(t, int64(12), ticket.Height)
+	requireOwned(t, s, false)
+}
+
+func testSetFailedWithErr(t *testing.T, s store.TicketStore) {
+	ctx := context.Background()
+	key := store.GetKey(testChain, testTxHash)
+	require.Error(t, s.SetFailedWithErrContext(ctx, key, testErr, 12))
+
+	createTicket(t, s)
+	require.NoError(t, s.SetFailedWithErrContext(ctx, key, testErr, 12))
+	ticket := requireStatus(t, s, key, store.StatusFailed)
+	require.Equal(t, testErr, ticket.Error)
+	require.Equal(t, int64(12), ticket.Height)
+	require.Equal(t, &store.TicketError{Category: store.CategoryUnknown, Message: testErr}, ticket.ErrorDetails)
+	requireOwned(t, s, false)
+}
+
+func testSetFailedWithTicketError(t *testing.T, s store.TicketStore) {
+	ctx := context.Background()
+	key := store.GetKey(testChain, testTxHash)
+	e := store.NewTicketError("sdk", 11, "out of gas in location: WriteFlat; gasWanted: 200000, gasUsed: 200120")
+	require.Error(t, s.SetFailedWithTicketErrorContext(ctx, key, e, 12))
+
+	createTicket(t, s)
+	require.NoError(t, s.SetFailedWithTicketErrorContext(ctx, key, e, 12))
+	ticket := requireStatus(t, s, key, store.StatusFailed)
+	require.Equal(t, &e, ticket.ErrorDetails)
+	require.Equal(t, e.Message, ticket.Error)
+
+	history, err := s.TicketHistoryContext(ctx, key)
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	require.Empty(t, history[0].ErrorCategory)
+	require.Equal(t, store.CategoryOutOfGas, history[1].ErrorCategory)
+	require.Equal(t, e.Message, history[1].Error)
+}
+
+func testSetTimeout(t *testing.T, s store.TicketStore) {
+	key := createTicket(t, s)
+	setInTransit(t, s, key)
+	require.NoError(t, s.SetTimeoutContext(context.Background(), key))
+	requireStatus(t, s, key, store.StatusTimeout)
+	requireOwned(t, s, false)
+}
+
+func testIbcReceived(t *testing.T, s store.TicketStore) {
+	ctx := context.Background()
+	key := createTicket(t, s)
+	ibcKey := setInTransit(t, s, key)
+
+	ticket := requireStatus(t, s, key, store.StatusTransit)
+	require.Equal(t, int64(10), ticket.Height)
+	requireOwned(t, s, true)
+
+	ibcTicket, err := s.GetContext(ctx, ibcKey)
+	require.NoError(t, err)
+	require.Equal(t, key, ibcTicket.Info)
+
+	require.NoError(t, s.SetIbcReceivedContext(ctx, ibcKey, "RECV", testDestChain, 11))
+	ticket = requireStatus(t, s, key, store.StatusIBCReceiveSuccess)
+	require.Equal(t, int64(11), ticket.Height)
+	require.Equal(t, []store.TxHashEntry{
+		{Chain: testChain, Status: store.StatusTransit, TxHash: testTxHash},
+		{Chain: testDestChain, Status: store.StatusIBCReceiveSuccess, TxHash: "RECV"},
+	}, ticket.TxHashes)
+	requireOwned(t, s, false)
+}
+
+func testIbcFailedAckUnlock(t *testing.T, s store.TicketStore) {
+	ctx := context.Background()
+	key := createTicket(t, s)
+	ibcKey := setInTransit(t, s, key)
+
+	require.NoError(t, s.SetIbcFailedContext(ctx, ibcKey, "RECV", testDestChain, 11))
+	requireStatus(t, s, key, store.StatusIBCReceiveFailed)
+	requireOwned(t, s, true)
+
+	require.NoError(t, s.SetIbcAckUnlockContext(ctx, ibcKey, "ACK", testChain, 12))
+	ticket := requireStatus(t, s, key, store.StatusTokensUnlockedAck)
+	require.Len(t, ticket.TxHashes, 2)
+	require.Equal(t, "ACK", ticket.TxHashes[1].TxHash)
+	requireOwned(t, s, false)
+}
+
+func testIbcTimeoutUnlock(t *testing.T, s store.TicketStore) {
+	ctx := context.Background()
+	key := createTicket(t, s)
+	ibcKey := setInTransit(t, s, key)
+
+	require.NoError(t, s.SetIbcTimeoutUnlockContext(ctx, ibcKey, "TIMEOUT", testChain, 12))
+	ticket := requireStatus(t, s, key, store.StatusTokensUnlockedTimeout)
+	require.Equal(t, store.StatusTokensUnlockedTimeout, ticket.TxHashes[1].Status)
+	requireOwned(t, s, false)
+}
+
+func testInvalidTransition(t *testing.T, s store.TicketStore) {
+	ctx := context.Background()
+	key := createTicket(t, s)
+	require.NoError(t, s.SetCompleteContext(ctx, key, 12))
+
+	require.ErrorIs(t, s.SetFailedWithErrContext(ctx, key, testErr, 13), store.ErrInvalidTransition)
+	require.ErrorIs(t, s.SetTimeoutContext(ctx, key), store.ErrInvalidTransition)
+	requireStatus(t, s, key, store.StatusComplete)
+}
+
+func testMissingTicket(t *testing.T, s store.TicketStore) {
+	ctx := context.Background()
+	key := store.GetKey(testChain, testTxHash)
+
+	_, err := s.GetContext(ctx, key)
+	require.ErrorIs(t, err, store.ErrTicketNotFound)
+	require.ErrorIs(t, s.SetCompleteContext(ctx, key, 12), store.ErrTicketNotFound)
+	require.Error(t, s.SetInTransitContext(ctx, key, testDestChain, testSrcChannel, testPktSeq, testTxHash, testChain, 10))
+	require.ErrorIs(t, s.SetIbcReceivedContext(ctx, "unknown", "RECV", testDestChain, 11), store.ErrTicketNotFound)
+
+	history, err := s.TicketHistoryContext(ctx, key)
+	require.NoError(t, err)
+	require.Empty(t, history)
+}
+
+func testHistory(t *testing.T, s store.TicketStore) {
+	ctx := context.Background()
+	key := createTicket(t, s)
+	ibcKey := setInTransit(t, s, key)
+	require.NoError(t, s.SetIbcReceivedContext(ctx, ibcKey, "RECV", testDestChain, 11))
+
+	history, err := s.TicketHistoryContext(ctx, key)
+	require.NoError(t, err)
+	require.Len(t, history, 3)
+
+	require.Equal(t, store.StatusPending, history[0].Status)
+	require.Equal(t, testTxHash, history[0].TxHash)
+	require.Equal(t, store.StatusTransit, history[1].Status)
+	require.Equal(t, int64(10), history[1].Height)
+	require.Equal(t, store.StatusIBCReceiveSuccess, history[2].Status)
+	require.Equal(t, "RECV", history[2].TxHash)
+	require.Equal(t, testDestChain, history[2].Chain)
+
+	for i := 1; i < len(history); i++ {
+		require.NotEqual(t, history[i-1].ID, history[i].ID)
+		require.False(t, history[i].Timestamp.Before(history[i-1].Timestamp))
+	}
+
+	// a ticket created over a previous one starts a new history
+	require.NoError(t, s.CreateTicketWithOptionsContext(ctx, testChain, testTxHash, testOwner, store.CreateOptions{Force: true}))
+	history, err = s.TicketHistoryContext(ctx, key)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+}
+
+func testListUserTickets(t *testing.T, s store.TicketStore) {
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		require.NoError(t, s.CreateTicketContext(ctx, testChain, fmt.Sprintf("HASH%d", i), testOwner))
+	}
+	require.NoError(t, s.CreateTicketContext(ctx, testDestChain, "OTHER", testOwner))
+	require.NoError(t, s.SetCompleteContext(ctx, store.GetKey(testChain, "HASH0"), 12))
+	require.NoError(t, s.SetInTransitContext(ctx, store.GetKey(testChain, "HASH1"), testDestChain, testSrcChannel, testPktSeq, testTxHash, testChain, 12))
+
+	page, err := s.ListUserTicketsContext(ctx, testOwner, store.ListFilter{}, 0)
+	require.NoError(t, err)
+	require.Zero(t, page.Cursor)
+	require.Len(t, page.Tickets, 5)
+	for _, ticket := range page.Tickets {
+		require.NoError(t, ticket.Err)
+	}
+
+	page, err = s.ListUserTicketsContext(ctx, testOwner, store.ListFilter{
+		Chains:   []string{testChain},
+		Statuses: []store.TicketStatus{store.StatusTransit},
+	}, 0)
+	require.NoError(t, err)
+	require.Len(t, page.Tickets, 1)
+	require.Equal(t, "HASH1", page.Tickets[0].TxHash)
+
+	seen := map[string]bool{}
+	var cursor uint64
+	for {
+		page, err := s.ListUserTicketsContext(ctx, testOwner, store.ListFilter{Limit: 2}, cursor)
+		require.NoError(t, err)
+		for _, ticket := range page.Tickets {
+			seen[ticket.Key] = true
+		}
+
+		cursor = page.Cursor
+		if cursor == 0 {
+			break
+		}
+	}
+	require.Len(t, seen, 5)
+
+	page, err = s.ListUserTicketsContext(ctx, "nobody", store.ListFilter{}, 0)
+	require.NoError(t, err)
+	require.Empty(t, page.Tickets)
+}
+
+func testMultiHop(t *testing.T, s store.TicketStore) {
+	ctx := context.Background()
+	key := createTicket(t, s)
+	firstKey, lastKey := setInTransitRoute(t, s, key)
+
+	ticket := requireStatus(t, s, key, store.StatusTransit)
+	require.Equal(t, []string{testDestChain, testLastChain}, ticket.Route)
+	require.Len(t, ticket.Hops, 2)
+	require.Equal(t, firstKey, ticket.Hops[0].PacketKey)
+	require.Equal(t, store.StatusIBCReceiveSuccess, ticket.Hops[0].Status)
+	require.Equal(t, lastKey, ticket.Hops[1].PacketKey)
+	require.Equal(t, store.StatusTransit, ticket.Hops[1].Status)
+	requireOwned(t, s, true)
+
+	lastTicket, err := s.GetContext(ctx, lastKey)
+	require.NoError(t, err)
+	require.Equal(t, key, lastTicket.Info)
+
+	require.NoError(t, s.SetIbcReceivedContext(ctx, lastKey, "RECV2", testLastChain, 12))
+	ticket = requireStatus(t, s, key, store.StatusIBCReceiveSuccess)
+	require.Equal(t, int64(12), ticket.Height)
+	require.Equal(t, []store.TxHashEntry{
+		{Chain: testChain, Status: store.StatusTransit, TxHash: testTxHash},
+		{Chain: testDestChain, Status: store.StatusIBCReceiveSuccess, TxHash: "RECV"},
+		{Chain: testDestChain, Status: store.StatusTransit, TxHash: "FORWARD"},
+		{Chain: testLastChain, Status: store.StatusIBCReceiveSuccess, TxHash: "RECV2"},
+	}, ticket.TxHashes)
+	requireOwned(t, s, false)
+
+	history, err := s.TicketHistoryContext(ctx, key)
+	require.NoError(t, err)
+	require.Len(t, history, 5)
+	require.Equal(t, store.StatusTransit, history[2].Status)
+	require.Equal(t, "RECV", history[2].TxHash)
+}
+
+func testMultiHopFailure(t *testing.T, s store.TicketStore) {
+	ctx := context.Background()
+	key := createTicket(t, s)
+	firstKey, lastKey := setInTransitRoute(t, s, key)
+
+	require.NoError(t, s.SetIbcTimeoutUnlockContext(ctx, lastKey, "TIMEOUT", testDestChain, 12))
+	ticket := requireStatus(t, s, key, store.StatusTokensUnlockedTimeout)
+	require.Equal(t, store.StatusTokensUnlockedTimeout, ticket.Hops[1].Status)
+	requireOwned(t, s, false)
+
+	require.ErrorIs(t, s.SetIbcReceivedContext(ctx, lastKey, "RECV2", testLastChain, 13), store.ErrInvalidTransition)
+
+	// a late receive on an intermediate hop leaves the ticket untouched
+	history, err := s.TicketHistoryContext(ctx, key)
+	require.NoError(t, err)
+	require.ErrorIs(t, s.SetIbcReceivedContext(ctx, firstKey, "RECV", testDestChain, 14), store.ErrInvalidTransition)
+	late := requireStatus(t, s, key, store.StatusTokensUnlockedTimeout)
+	require.Equal(t, ticket, late)
+	lateHistory, err := s.TicketHistoryContext(ctx, key)
+	require.NoError(t, err)
+	require.Len(t, lateHistory, len(history))
+}
+
+func testInvalidHop(t *testing.T, s store.TicketStore) {
+	ctx := context.Background()
+	key := createTicket(t, s)
+
+	require.ErrorIs(t, s.SetInTransitRouteContext(ctx, key, nil, testSrcChannel, testPktSeq, testTxHash, testChain, 10), store.ErrInvalidHop)
+	requireStatus(t, s, key, store.StatusPending)
+
+	firstKey, lastKey := setInTransitRoute(t, s, key)
+
+	// the route ends at testLastChain
+	require.ErrorIs(t, s.AddHopContext(ctx, lastKey, "juno", "channel-3", "1", "FORWARD2", testLastChain, 12), store.ErrInvalidHop)
+	// the first hop is already followed by the second one
+	require.ErrorIs(t, s.AddHopContext(ctx, firstKey, testLastChain, "channel-2", "6", "FORWARD2", testDestChain, 12), store.ErrInvalidHop)
+
+	ticket := requireStatus(t, s, key, store.StatusTransit)
+	require.Len(t, ticket.Hops, 2)
+}
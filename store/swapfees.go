@@ -0,0 +1,338 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/go-redis/redis/v8"
+	"github.com/gofrs/uuid"
+)
+
+const (
+	swapFeesPrefix = "swapfees"
+
+	// legacySwapFeesBatch is the number of legacy swap fees moved at once by
+	// MigrateSwapFees.
+	legacySwapFeesBatch = 1000
+)
+
+// legacySwapFeesRegexp matches the keys swap fees were stored at before being
+// indexed by time, pool/<id>/<unix seconds>.
+var legacySwapFeesRegexp = regexp.MustCompile(`^pool/(.+)/(\d+)$`)
+
+// Swap fees of a pool are members of a sorted set scored by the time of the
+// swap, in milliseconds. Members are the fee coin prefixed by a random id, so
+// that identical fees paid at the same time are all recorded.
+
+func swapFeesKey(poolId string) string {
+	return fmt.Sprintf("%s/%s", swapFeesPrefix, poolId)
+}
+
+func toMillis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+func swapFeesScore(t time.Time) string {
+	return strconv.FormatInt(toMillis(t), 10)
+}
+
+// SetPoolSwapFees records a swap fee paid in the pool.
+// Swap fees were previously stored at pool/<id>/<unix seconds>, which
+// GetSwapFees no longer reads: MigrateSwapFees must run once every replica
+// runs this version, so that the fees paid over the retention period keep
+// being counted.
+func (s *Store) SetPoolSwapFees(poolId, offerCoinAmount, offerCoinDenom string) error {
+	return s.SetPoolSwapFeesContext(context.Background(), poolId, offerCoinAmount, offerCoinDenom)
+}
+
+// SetPoolSwapFeesContext is like SetPoolSwapFees, with a context.
+func (s *Store) SetPoolSwapFeesContext(ctx context.Context, poolId, offerCoinAmount, offerCoinDenom string) error {
+	offerCoinAmountInt, ok := sdk.NewIntFromString(offerCoinAmount)
+	if !ok {
+		return fmt.Errorf("unable to convert offerCoinAmout to sdk Int")
+	}
+
+	coin := sdk.NewCoin(offerCoinDenom, offerCoinAmountInt)
+
+	id, err := uuid.NewV4()
+	if err != nil {
+		return fmt.Errorf("cannot generate swap fee id, %w", err)
+	}
+
+	now := time.Now()
+	key := swapFeesKey(poolId)
+	retention := s.swapFeesExpiry()
+
+	_, err = s.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(ctx, key, &redis.Z{
+			Score:  float64(toMillis(now)),
+			Member: id.String() + ":" + coin.String(),
+		})
+		// fees older than the retention are no longer needed
+		pipe.ZRemRangeByScore(ctx, key, "-inf", "("+swapFeesScore(now.Add(-retention)))
+		pipe.Expire(ctx, key, retention)
+		return nil
+	})
+
+	return err
+}
+
+// GetSwapFees returns the sum of the swap fees paid in the pool over the
+// retention period, one hour by default.
+func (s *Store) GetSwapFees(poolId string) (sdk.Coins, error) {
+	return s.GetSwapFeesContext(context.Background(), poolId)
+}
+
+// GetSwapFeesContext is like GetSwapFees, with a context.
+func (s *Store) GetSwapFeesContext(ctx context.Context, poolId string) (sdk.Coins, error) {
+	fees, err := s.swapFees(ctx, poolId, swapFeesScore(time.Now().Add(-s.swapFeesExpiry())), "+inf")
+	if err != nil {
+		return sdk.Coins{}, err
+	}
+
+	var coins sdk.Coins
+	for _, f := range fees {
+		coins = coins.Add(f.coin)
+	}
+
+	return coins, nil
+}
+
+// SwapFeesBetween returns the sum of the swap fees paid in the pool from
+// from, included, to to, excluded.
+// Fees older than the retention period are no longer available.
+func (s *Store) SwapFeesBetween(poolId string, from, to time.Time) (sdk.Coins, error) {
+	return s.SwapFeesBetweenContext(context.Background(), poolId, from, to)
+}
+
+// SwapFeesBetweenContext is like SwapFeesBetween, with a context.
+func (s *Store) SwapFeesBetweenContext(ctx context.Context, poolId string, from, to time.Time) (sdk.Coins, error) {
+	if !from.Before(to) {
+		return sdk.Coins{}, fmt.Errorf("invalid swap fees range %s - %s", from, to)
+	}
+
+	fees, err := s.swapFees(ctx, poolId, swapFeesScore(from), "("+swapFeesScore(to))
+	if err != nil {
+		return sdk.Coins{}, err
+	}
+
+	var coins sdk.Coins
+	for _, f := range fees {
+		coins = coins.Add(f.coin)
+	}
+
+	return coins, nil
+}
+
+// SwapFeesBucket holds the swap fees paid in a pool from Start, included, to
+// End, excluded.
+type SwapFeesBucket struct {
+	Start time.Time
+	End   time.Time
+	Fees  sdk.Coins
+}
+
+// SwapFeesBucketed splits the window ending now in buckets of bucketSize and
+// returns the swap fees paid in the pool during each of them, from the
+// oldest to the most recent. The oldest bucket is shorter when window is not
+// a multiple of bucketSize.
+// Fees older than the retention period are no longer available.
+func (s *Store) SwapFeesBucketed(poolId string, window, bucketSize time.Duration) ([]SwapFeesBucket, error) {
+	return s.SwapFeesBucketedContext(context.Background(), poolId, window, bucketSize)
+}
+
+// SwapFeesBucketedContext is like SwapFeesBucketed, with a context.
+func (s *Store) SwapFeesBucketedContext(ctx context.Context, poolId string, window, bucketSize time.Duration) ([]SwapFeesBucket, error) {
+	if window <= 0 || bucketSize <= 0 || bucketSize > window {
+		return nil, fmt.Errorf("invalid swap fees buckets of %s over %s", bucketSize, window)
+	}
+
+	end := time.Now()
+	start := end.Add(-window)
+
+	// buckets are laid out backwards from end
+	n := int((window + bucketSize - 1) / bucketSize)
+	buckets := make([]SwapFeesBucket, n)
+	for i := range buckets {
+		bucketEnd := end.Add(-time.Duration(n-1-i) * bucketSize)
+		bucketStart := bucketEnd.Add(-bucketSize)
+		if bucketStart.Before(start) {
+			bucketStart = start
+		}
+
+		buckets[i] = SwapFeesBucket{
+			Start: bucketStart,
+			End:   bucketEnd,
+			Fees:  sdk.Coins{},
+		}
+	}
+
+	fees, err := s.swapFees(ctx, poolId, swapFeesScore(start), "("+swapFeesScore(end))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range fees {
+		i := n - 1 - int(end.Sub(f.time)/bucketSize)
+		if end.Sub(f.time)%bucketSize == 0 {
+			// fees paid right on a boundary belong to the bucket starting there
+			i++
+		}
+		if i < 0 || i >= n {
+			continue
+		}
+
+		buckets[i].Fees = buckets[i].Fees.Add(f.coin)
+	}
+
+	return buckets, nil
+}
+
+type swapFee struct {
+	time time.Time
+	coin sdk.Coin
+}
+
+// swapFees returns the swap fees of the pool scored between min and max.
+func (s *Store) swapFees(ctx context.Context, poolId, min, max string) ([]swapFee, error) {
+	res, err := s.Client.ZRangeByScoreWithScores(ctx, swapFeesKey(poolId), &redis.ZRangeBy{
+		Min: min,
+		Max: max,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	fees := make([]swapFee, 0, len(res))
+	for _, z := range res {
+		member, _ := z.Member.(string)
+		coin, err := parseSwapFee(member)
+		if err != nil {
+			return nil, err
+		}
+
+		ms := int64(z.Score)
+		fees = append(fees, swapFee{
+			time: time.Unix(0, ms*int64(time.Millisecond)),
+			coin: coin,
+		})
+	}
+
+	return fees, nil
+}
+
+func parseSwapFee(member string) (sdk.Coin, error) {
+	idx := strings.Index(member, ":")
+	if idx < 0 {
+		return sdk.Coin{}, fmt.Errorf("malformed swap fee %s", member)
+	}
+
+	return sdk.ParseCoinNormalized(member[idx+1:])
+}
+
+// MigrateSwapFees moves the swap fees stored at pool/<id>/<unix seconds>, as
+// written before they were indexed by time, to the sorted set of their pool,
+// and returns the number of fees moved. Fees older than the retention period
+// are deleted.
+// Moved fees keep the second they were paid at, and are identified by their
+// legacy key, so MigrateSwapFees can run again if interrupted, or while
+// replicas running a previous version still write legacy keys.
+func (s *Store) MigrateSwapFees() (int, error) {
+	return s.MigrateSwapFeesContext(context.Background())
+}
+
+// MigrateSwapFeesContext is like MigrateSwapFees, with a context.
+func (s *Store) MigrateSwapFeesContext(ctx context.Context) (int, error) {
+	var (
+		mu   sync.Mutex
+		keys []string
+	)
+	err := s.forEachNode(ctx, func(ctx context.Context, client redis.UniversalClient) error {
+		iter := client.ScanType(ctx, 0, "pool/*", legacySwapFeesBatch, "string").Iterator()
+		for iter.Next(ctx) {
+			if legacySwapFeesRegexp.MatchString(iter.Val()) {
+				mu.Lock()
+				keys = append(keys, iter.Val())
+				mu.Unlock()
+			}
+		}
+
+		return iter.Err()
+	})
+	if err != nil {
+		return 0, fmt.Errorf("cannot scan legacy swap fees, %w", err)
+	}
+
+	moved := 0
+	for len(keys) > 0 {
+		n := legacySwapFeesBatch
+		if n > len(keys) {
+			n = len(keys)
+		}
+
+		batchMoved, err := s.migrateSwapFees(ctx, keys[:n])
+		moved += batchMoved
+		if err != nil {
+			return moved, err
+		}
+
+		keys = keys[n:]
+	}
+
+	return moved, nil
+}
+
+// migrateSwapFees moves the legacy swap fees stored at keys, and returns the
+// number of fees moved.
+func (s *Store) migrateSwapFees(ctx context.Context, keys []string) (int, error) {
+	// SCAN may return a key more than once, and keys may expire meanwhile
+	values, _, err := getMany(ctx, s.Client, keys)
+	if err != nil {
+		return 0, fmt.Errorf("cannot read legacy swap fees, %w", err)
+	}
+
+	retention := s.swapFeesExpiry()
+	minTime := time.Now().Add(-retention)
+
+	moved := 0
+	_, err = s.Client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for key, v := range values {
+			m := legacySwapFeesRegexp.FindStringSubmatch(key)
+			sec, err := strconv.ParseInt(m[2], 10, 64)
+			if err != nil {
+				return fmt.Errorf("malformed legacy swap fees key %s, %w", key, err)
+			}
+
+			paidAt := time.Unix(sec, 0)
+			if paidAt.After(minTime) {
+				coin, err := sdk.ParseCoinNormalized(v)
+				if err != nil {
+					return fmt.Errorf("malformed legacy swap fee %s, %w", key, err)
+				}
+
+				poolKey := swapFeesKey(m[1])
+				pipe.ZAdd(ctx, poolKey, &redis.Z{
+					Score:  float64(toMillis(paidAt)),
+					Member: key + ":" + coin.String(),
+				})
+				pipe.Expire(ctx, poolKey, retention)
+				moved++
+			}
+
+			pipe.Del(ctx, key)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("cannot move legacy swap fees, %w", err)
+	}
+
+	return moved, nil
+}
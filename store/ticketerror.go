@@ -0,0 +1,163 @@
+package store
+
+import (
+	"strings"
+
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+)
+
+// ErrorCategory classifies the errors of failed tickets, for clients to tell
+// them apart.
+type ErrorCategory string
+
+const (
+	CategoryUnknown           ErrorCategory = "unknown"
+	CategoryOutOfGas          ErrorCategory = "out_of_gas"
+	CategoryInsufficientFunds ErrorCategory = "insufficient_funds"
+	CategoryInsufficientFee   ErrorCategory = "insufficient_fee"
+	CategoryInvalidSequence   ErrorCategory = "invalid_sequence"
+	CategoryUnauthorized      ErrorCategory = "unauthorized"
+	CategoryInvalidAddress    ErrorCategory = "invalid_address"
+	CategoryMempool           ErrorCategory = "mempool"
+	CategoryTimeout           ErrorCategory = "timeout"
+	CategoryChannelClosed     ErrorCategory = "channel_closed"
+	CategoryTransferDisabled  ErrorCategory = "transfer_disabled"
+)
+
+// IBC codespaces and codes, as registered by ibc-go.
+const (
+	ibcChannelCodespace = "channel"
+	ibcPacketTimeout    = 14
+)
+
+var categoryMessages = map[ErrorCategory]string{
+	CategoryUnknown:           "the transaction failed",
+	CategoryOutOfGas:          "the transaction ran out of gas",
+	CategoryInsufficientFunds: "insufficient funds",
+	CategoryInsufficientFee:   "insufficient fee",
+	CategoryInvalidSequence:   "invalid account sequence, the transaction can be signed again",
+	CategoryUnauthorized:      "unauthorized",
+	CategoryInvalidAddress:    "invalid address",
+	CategoryMempool:           "the transaction was rejected by the mempool",
+	CategoryTimeout:           "the transaction or its IBC packet timed out",
+	CategoryChannelClosed:     "the IBC channel is closed",
+	CategoryTransferDisabled:  "IBC transfers are disabled on the chain",
+}
+
+// sdkCategories classifies the errors of the sdk codespace by ABCI code.
+var sdkCategories = map[uint32]ErrorCategory{
+	sdkerrors.ErrOutOfGas.ABCICode():          CategoryOutOfGas,
+	sdkerrors.ErrInsufficientFunds.ABCICode(): CategoryInsufficientFunds,
+	sdkerrors.ErrInsufficientFee.ABCICode():   CategoryInsufficientFee,
+	sdkerrors.ErrInvalidSequence.ABCICode():   CategoryInvalidSequence,
+	sdkerrors.ErrWrongSequence.ABCICode():     CategoryInvalidSequence,
+	sdkerrors.ErrUnauthorized.ABCICode():      CategoryUnauthorized,
+	sdkerrors.ErrInvalidAddress.ABCICode():    CategoryInvalidAddress,
+	sdkerrors.ErrUnknownAddress.ABCICode():    CategoryInvalidAddress,
+	sdkerrors.ErrTxInMempoolCache.ABCICode():  CategoryMempool,
+	sdkerrors.ErrMempoolIsFull.ABCICode():     CategoryMempool,
+	sdkerrors.ErrTxTimeoutHeight.ABCICode():   CategoryTimeout,
+}
+
+// rawLogCategories classifies errors by the lower cased messages found in
+// their raw log, when their code is not enough. The first match wins.
+var rawLogCategories = []struct {
+	pattern  string
+	category ErrorCategory
+}{
+	{"out of gas", CategoryOutOfGas},
+	{"insufficient funds", CategoryInsufficientFunds},
+	{"insufficient fee", CategoryInsufficientFee},
+	{"account sequence mismatch", CategoryInvalidSequence},
+	{"incorrect account sequence", CategoryInvalidSequence},
+	{"unauthorized", CategoryUnauthorized},
+	{"invalid address", CategoryInvalidAddress},
+	{"tx already in mempool", CategoryMempool},
+	{"mempool is full", CategoryMempool},
+	{"packet timeout", CategoryTimeout},
+	{"timeout height", CategoryTimeout},
+	{"timeout timestamp", CategoryTimeout},
+	{"invalid channel state", CategoryChannelClosed},
+	{"channel is closed", CategoryChannelClosed},
+	{"transfers to this chain are disabled", CategoryTransferDisabled},
+	{"transfers from this chain are disabled", CategoryTransferDisabled},
+}
+
+// TicketError describes the error of a failed ticket. Its Message is also
+// stored as the Error of the ticket, for clients reading the plain error.
+type TicketError struct {
+	// Codespace and Code are the ABCI error of the failed transaction,
+	// if any.
+	Codespace string        `json:"codespace,omitempty"`
+	Code      uint32        `json:"code,omitempty"`
+	Category  ErrorCategory `json:"category"`
+	Message   string        `json:"message"`
+	RawLog    string        `json:"raw_log,omitempty"`
+}
+
+// NewTicketError returns the classified error of a transaction that failed
+// with code in codespace, or that logged rawLog.
+func NewTicketError(codespace string, code uint32, rawLog string) TicketError {
+	return TicketError{
+		Codespace: codespace,
+		Code:      code,
+		RawLog:    rawLog,
+	}.withDefaults()
+}
+
+// withDefaults classifies e, and describes its category, when unset.
+func (e TicketError) withDefaults() TicketError {
+	if e.Category == "" {
+		e.Category = ClassifyError(e.Codespace, e.Code, e.RawLog)
+	}
+
+	if e.Message == "" {
+		e.Message = categoryMessages[e.Category]
+	}
+
+	if e.Message == "" {
+		e.Message = categoryMessages[CategoryUnknown]
+	}
+
+	return e
+}
+
+// failedTransition moves the ticket stored at key to StatusFailed with e.
+func failedTransition(key string, e TicketError, height int64) ticketTransition {
+	e = e.withDefaults()
+
+	return ticketTransition{
+		key:  key,
+		next: StatusFailed,
+		update: func(t *Ticket) {
+			t.Height = height
+			t.Error = e.Message
+			t.ErrorDetails = &e
+		},
+	}
+}
+
+// ClassifyError returns the category of the error with code in codespace,
+// falling back to the messages found in rawLog. CategoryUnknown is returned
+// when neither is recognized.
+func ClassifyError(codespace string, code uint32, rawLog string) ErrorCategory {
+	switch codespace {
+	case sdkerrors.RootCodespace:
+		if c, ok := sdkCategories[code]; ok {
+			return c
+		}
+	case ibcChannelCodespace:
+		if code == ibcPacketTimeout {
+			return CategoryTimeout
+		}
+	}
+
+	log := strings.ToLower(rawLog)
+	for _, c := range rawLogCategories {
+		if strings.Contains(log, c.pattern) {
+			return c.category
+		}
+	}
+
+	return CategoryUnknown
+}
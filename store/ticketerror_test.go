@@ -0,0 +1,69 @@
+package store
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		codespace string
+		code      uint32
+		rawLog    string
+		category  ErrorCategory
+	}{
+		{"sdk", 11, "", CategoryOutOfGas},
+		{"sdk", 5, "0uatom is smaller than 10uatom: insufficient funds", CategoryInsufficientFunds},
+		{"sdk", 13, "", CategoryInsufficientFee},
+		{"sdk", 32, "", CategoryInvalidSequence},
+		{"sdk", 19, "", CategoryMempool},
+		{"sdk", 30, "", CategoryTimeout},
+		{"channel", 14, "", CategoryTimeout},
+		// unknown codes fall back to the raw log
+		{"sdk", 1000, "account sequence mismatch, expected 12, got 11: incorrect account sequence", CategoryInvalidSequence},
+		{"", 0, "failed to execute message; message index: 0: Out of gas", CategoryOutOfGas},
+		{"transfer", 8, "fungible token transfers to this chain are disabled", CategoryTransferDisabled},
+		{"channel", 5, "channel state is not OPEN (got STATE_CLOSED): invalid channel state", CategoryChannelClosed},
+		{"wasm", 5, "execute wasm contract failed", CategoryUnknown},
+		{"", 0, "", CategoryUnknown},
+	}
+
+	for _, tt := range tests {
+		require.Equal(t, tt.category, ClassifyError(tt.codespace, tt.code, tt.rawLog), "%s/%d %s", tt.codespace, tt.code, tt.rawLog)
+	}
+}
+
+func TestNewTicketError(t *testing.T) {
+	e := NewTicketError("sdk", 11, "out of gas in location: WriteFlat")
+	require.Equal(t, CategoryOutOfGas, e.Category)
+	require.Equal(t, "the transaction ran out of gas", e.Message)
+
+	e = TicketError{Category: "custom"}.withDefaults()
+	require.Equal(t, ErrorCategory("custom"), e.Category)
+	require.Equal(t, "the transaction failed", e.Message)
+}
+
+func TestTicketErrorJSON(t *testing.T) {
+	// tickets failed before errors were structured
+	var ticket Ticket
+	require.NoError(t, json.Unmarshal([]byte(`{"status":"failed","error":"dummy error"}`), &ticket))
+	require.Equal(t, "dummy error", ticket.Error)
+	require.Nil(t, ticket.ErrorDetails)
+
+	e := NewTicketError("sdk", 5, "")
+	ticket = Ticket{Status: StatusFailed, Error: e.Message, ErrorDetails: &e}
+	bz, err := json.Marshal(ticket)
+	require.NoError(t, err)
+
+	var fields map[string]interface{}
+	require.NoError(t, json.Unmarshal(bz, &fields))
+	require.Equal(t, "insufficient funds", fields["error"])
+	require.Equal(t, map[string]interface{}{
+		"codespace": "sdk",
+		"code":      5.0,
+		"category":  "insufficient_funds",
+		"message":   "insufficient funds",
+	}, fields["error_details"])
+}
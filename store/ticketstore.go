@@ -0,0 +1,40 @@
+package store
+
+import (
+	"context"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ErrTicketNotFound is returned when a ticket doesn't exist. It is redis.Nil,
+// so that existing checks against redis.Nil keep working.
+var ErrTicketNotFound = redis.Nil
+
+// TicketStore is the ticket lifecycle, as implemented by Store and
+// MemoryStore. The storetest package holds the tests both implementations
+// pass.
+type TicketStore interface {
+	CreateTicketContext(ctx context.Context, chain, txHash, owner string) error
+	CreateTicketWithOptionsContext(ctx context.Context, chain, txHash, owner string, opts CreateOptions) error
+	GetContext(ctx context.Context, key string) (Ticket, error)
+	GetUserTicketsContext(ctx context.Context, user string) (map[string][]string, error)
+	ListUserTicketsContext(ctx context.Context, owner string, filter ListFilter, cursor uint64) (TicketPage, error)
+	TicketHistoryContext(ctx context.Context, key string) ([]TicketHistoryEntry, error)
+
+	SetInTransitContext(ctx context.Context, key, destChain, sourceChannel, sendPacketSequence, txHash, chainName string, height int64) error
+	SetInTransitRouteContext(ctx context.Context, key string, route []string, sourceChannel, sendPacketSequence, txHash, chainName string, height int64) error
+	AddHopContext(ctx context.Context, prevKey, destChain, sourceChannel, sendPacketSequence, txHash, chainName string, height int64) error
+	SetIbcReceivedContext(ctx context.Context, key, txHash, chainName string, height int64) error
+	SetIbcFailedContext(ctx context.Context, key, txHash, chainName string, height int64) error
+	SetIbcTimeoutUnlockContext(ctx context.Context, key, txHash, chainName string, height int64) error
+	SetIbcAckUnlockContext(ctx context.Context, key, txHash, chainName string, height int64) error
+	SetCompleteContext(ctx context.Context, key string, height int64) error
+	SetFailedWithErrContext(ctx context.Context, key, error string, height int64) error
+	SetFailedWithTicketErrorContext(ctx context.Context, key string, e TicketError, height int64) error
+	SetTimeoutContext(ctx context.Context, key string) error
+}
+
+var (
+	_ TicketStore = (*Store)(nil)
+	_ TicketStore = (*MemoryStore)(nil)
+)
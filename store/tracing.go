@@ -0,0 +1,87 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/go-redis/redis/v8"
+
+	"github.com/emerishq/emeris-utils/logging"
+	"github.com/emerishq/emeris-utils/sentryx"
+)
+
+const redisSpanOp = "db.redis"
+
+type spanCtxKey struct{}
+
+// tracingHook carries the values of the context passed to Store methods to
+// the redis commands they issue: when ctx holds a sentry transaction, as set
+// by sentryx.GinMiddleware, every command runs in a child span tagged with the
+// correlation IDs set by logging.AddLoggerMiddleware.
+type tracingHook struct{}
+
+func (tracingHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return startSpan(ctx, cmd.Name()), nil
+}
+
+func (tracingHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	finishSpan(ctx, cmd.Err())
+	return nil
+}
+
+func (tracingHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	names := make([]string, 0, len(cmds))
+	for _, cmd := range cmds {
+		names = append(names, cmd.Name())
+	}
+
+	return startSpan(ctx, strings.Join(names, " ")), nil
+}
+
+func (tracingHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	var err error
+	for _, cmd := range cmds {
+		if cmd.Err() != nil {
+			err = cmd.Err()
+			break
+		}
+	}
+
+	finishSpan(ctx, err)
+	return nil
+}
+
+func startSpan(ctx context.Context, description string) context.Context {
+	// commands issued outside of a traced request don't start a transaction
+	// on their own
+	if sentry.TransactionFromContext(ctx) == nil {
+		return ctx
+	}
+
+	span, ctx := sentryx.StartSpan(ctx, redisSpanOp)
+	span.Description = description
+	for _, k := range []interface{}{logging.CorrelationIDName, logging.IntCorrelationIDName} {
+		if v := ctx.Value(k); v != nil {
+			span.SetTag(fmt.Sprint(k), fmt.Sprint(v))
+		}
+	}
+
+	return context.WithValue(ctx, spanCtxKey{}, span)
+}
+
+func finishSpan(ctx context.Context, err error) {
+	span, ok := ctx.Value(spanCtxKey{}).(*sentry.Span)
+	if !ok {
+		return
+	}
+
+	span.Status = sentry.SpanStatusOK
+	if err != nil && !errors.Is(err, redis.Nil) {
+		span.Status = sentry.SpanStatusInternalError
+	}
+
+	span.Finish()
+}
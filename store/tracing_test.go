@@ -0,0 +1,51 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/stretchr/testify/require"
+
+	"github.com/emerishq/emeris-utils/logging"
+)
+
+func TestContextCancellation(t *testing.T) {
+	defer ResetTestStore(mr, store)
+	require.NoError(t, store.CreateTicket(testChain, testTxHash, testOwner))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := store.GetContext(ctx, GetKey(testChain, testTxHash))
+	require.ErrorIs(t, err, context.Canceled)
+	require.ErrorIs(t, store.SetCompleteContext(ctx, GetKey(testChain, testTxHash), 123), context.Canceled)
+	_, err = NewBlocks(store).BlockContext(ctx, 123)
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestTracingHook(t *testing.T) {
+	// no transaction in context, no span
+	ctx := startSpan(context.Background(), "get")
+	require.Nil(t, ctx.Value(spanCtxKey{}))
+
+	tx := sentry.StartSpan(context.Background(), "http.server")
+	defer tx.Finish()
+	ctx = context.WithValue(tx.Context(), logging.IntCorrelationIDName, "int-id")
+
+	ctx = startSpan(ctx, "get")
+	span, ok := ctx.Value(spanCtxKey{}).(*sentry.Span)
+	require.True(t, ok)
+	require.Equal(t, redisSpanOp, span.Op)
+	require.Equal(t, "get", span.Description)
+	require.Equal(t, tx.SpanID, span.ParentSpanID)
+	require.Equal(t, "int-id", span.Tags[string(logging.IntCorrelationIDName)])
+	_, ok = span.Tags[string(logging.CorrelationIDName)]
+	require.False(t, ok)
+
+	finishSpan(ctx, nil)
+	require.Equal(t, sentry.SpanStatusOK, span.Status)
+
+	// commands issued through the store run in the request transaction
+	_, err := store.GetContext(tx.Context(), "missing")
+	require.Error(t, err)
+}
@@ -0,0 +1,170 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ErrConflict is returned when a ticket is modified by another writer while a
+// transition is being applied to it. No change is written in that case, the
+// caller can re-read the ticket and retry.
+var ErrConflict = errors.New("ticket modified concurrently")
+
+// ticketTransition describes a ticket status change.
+type ticketTransition struct {
+	key  string
+	next TicketStatus
+	// tx is the transaction that caused the change, if any
+	tx *TxHashEntry
+	// update receives a copy of the stored ticket and applies the changes
+	// that come with the new status
+	update func(t *Ticket)
+	// extra queues additional writes that must happen in the same
+	// transaction
+	extra func(pipe redis.Pipeliner, t Ticket)
+	// resolve, if not nil, replaces next: it picks the new status from the
+	// stored ticket, an empty status keeps the current one
+	resolve func(t Ticket) (TicketStatus, error)
+}
+
+// nextStatus returns the status the ticket t moves to.
+func (tr ticketTransition) nextStatus(t Ticket) (TicketStatus, error) {
+	next := tr.next
+	if tr.resolve != nil {
+		var err error
+		if next, err = tr.resolve(t); err != nil {
+			return "", err
+		}
+
+		if next == "" {
+			// terminal tickets take no further change, even without a
+			// status change
+			if t.Status.IsTerminal() {
+				return "", fmt.Errorf("%w: %s is already %s", ErrInvalidTransition, tr.key, t.Status)
+			}
+
+			return t.Status, nil
+		}
+	}
+
+	if !t.Status.CanTransitionTo(next) {
+		return "", fmt.Errorf("%w: %s from %s to %s", ErrInvalidTransition, tr.key, t.Status, next)
+	}
+
+	return next, nil
+}
+
+// transition atomically applies tr to the ticket stored at tr.key.
+// Non-terminal tickets get their shadow key refreshed, terminal ones have it
+// deleted and are removed from their owner's set. The change is appended to
+// the ticket history, and a TicketEvent is published once the transaction is
+// committed.
+// In cluster mode, the writes on keys living outside of the ticket slot are
+// applied right after the transaction.
+func (s *Store) transition(ctx context.Context, tr ticketTransition) error {
+	key := tr.key
+
+	var (
+		crossSlot func(pipe redis.Pipeliner)
+		prev      TicketStatus
+		ticket    Ticket
+	)
+
+	err := s.Client.Watch(ctx, func(tx *redis.Tx) error {
+		ticket = Ticket{}
+		if err := tx.Get(ctx, key).Scan(&ticket); err != nil {
+			return err
+		}
+
+		next, err := tr.nextStatus(ticket)
+		if err != nil {
+			return err
+		}
+
+		prev = ticket.Status
+		if tr.update != nil {
+			tr.update(&ticket)
+		}
+		ticket.Status = next
+
+		expiry := s.ticketExpiry(next)
+
+		crossSlot = func(pipe redis.Pipeliner) {
+			pipe.Del(ctx, legacyShadowKey(key))
+			if next.IsTerminal() {
+				pipe.SRem(ctx, ticket.Owner, key)
+			}
+			countOpenTickets(ctx, pipe, prev, next)
+
+			if tr.extra != nil {
+				tr.extra(pipe, ticket)
+			}
+
+			s.publishEvent(ctx, pipe, TicketEvent{
+				Key:       key,
+				Owner:     ticket.Owner,
+				OldStatus: prev,
+				NewStatus: next,
+				Height:    ticket.Height,
+				TxHashes:  ticket.TxHashes,
+			})
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, ticket, expiry)
+
+			if next.IsTerminal() {
+				pipe.Del(ctx, shadowKey(key))
+			} else {
+				pipe.Set(ctx, shadowKey(key), "", s.shadowExpiry())
+			}
+
+			entry := TicketHistoryEntry{
+				Status:    next,
+				Height:    ticket.Height,
+				Error:     ticket.Error,
+				Timestamp: time.Now(),
+			}
+			if ticket.ErrorDetails != nil {
+				entry.ErrorCategory = ticket.ErrorDetails.Category
+			}
+			if tr.tx != nil {
+				entry.Chain = tr.tx.Chain
+				entry.TxHash = tr.tx.TxHash
+			}
+			appendHistory(ctx, pipe, key, entry, expiry)
+
+			if !s.isCluster() {
+				crossSlot(pipe)
+			}
+
+			return nil
+		})
+
+		return err
+	}, key)
+
+	if errors.Is(err, redis.TxFailedErr) {
+		return fmt.Errorf("%w: %s", ErrConflict, key)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	s.observeTransition(prev, ticket)
+	if !s.isCluster() {
+		return nil
+	}
+
+	_, err = s.Client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		crossSlot(pipe)
+		return nil
+	})
+
+	return err
+}
@@ -0,0 +1,46 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransitionConflict(t *testing.T) {
+	defer ResetTestStore(mr, store)
+	key := GetKey(testChain, testTxHash)
+	require.NoError(t, store.CreateTicket(testChain, testTxHash, testOwner))
+	// another writer fails the ticket while it's being completed
+	err := store.transition(context.Background(), ticketTransition{
+		key:  key,
+		next: StatusComplete,
+		update: func(ticket *Ticket) {
+			require.NoError(t, store.SetFailedWithErr(key, testErr, 123))
+		},
+	})
+	require.ErrorIs(t, err, ErrConflict)
+	// the concurrent write is the one that landed
+	ticket, err := store.Get(key)
+	require.NoError(t, err)
+	require.Equal(t, StatusFailed, ticket.Status)
+	require.Equal(t, testErr, ticket.Error)
+	require.False(t, store.Exists(getShadowKey(key)))
+	tickets, err := store.GetUserTickets(testOwner)
+	require.NoError(t, err)
+	require.Len(t, tickets[testChain], 0)
+}
+
+func TestTransitionKeepsOwner(t *testing.T) {
+	defer ResetTestStore(mr, store)
+	key := GetKey(testChain, testTxHash)
+	require.NoError(t, store.CreateTicket(testChain, testTxHash, testOwner))
+	created, err := store.Get(key)
+	require.NoError(t, err)
+	require.NoError(t, store.SetComplete(key, 123))
+	ticket, err := store.Get(key)
+	require.NoError(t, err)
+	require.Equal(t, created.Owner, ticket.Owner)
+	require.EqualValues(t, 123, ticket.Height)
+	require.Equal(t, 2*store.Config.ExpiryTime, mr.TTL(key))
+}